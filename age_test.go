@@ -0,0 +1,92 @@
+package rotatelogs_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxAgeDuration(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	today := testTime
+	yesterday := testTime.AddDate(0, 0, -1)
+	// testTime is noon, so with a 6h maximum age the cutoff is 06:00
+	recent := today.Add(-2 * time.Hour)
+	early := today.Add(-7 * time.Hour)
+
+	t.Run("Ages from file names", func(t *testing.T) {
+		old := writeLogFile(t, dir, yesterday, "old", recent)
+		current := writeLogFile(t, dir, today, "current", early)
+		defer os.Remove(old)
+		defer os.Remove(current)
+
+		rl := newLogger(t, dir,
+			rotatelogs.WithClock(clockwork.NewFakeClockAt(testTime)),
+			rotatelogs.WithMaxAgeDuration(6*time.Hour),
+		)
+		defer rl.Close()
+
+		expired, err := rl.ExpiredFiles()
+		if !assert.NoError(t, err) {
+			return
+		}
+		// yesterday's file ages from midnight, today's from tonight
+		assert.Equal(t, []string{old}, expired)
+	})
+
+	t.Run("Ages from modification times", func(t *testing.T) {
+		old := writeLogFile(t, dir, yesterday, "old", recent)
+		current := writeLogFile(t, dir, today, "current", early)
+		defer os.Remove(old)
+		defer os.Remove(current)
+
+		rl := newLogger(t, dir,
+			rotatelogs.WithClock(clockwork.NewFakeClockAt(testTime)),
+			rotatelogs.WithMaxAgeDuration(6*time.Hour),
+			rotatelogs.WithAgeSource(rotatelogs.AgeFromModTime),
+		)
+		defer rl.Close()
+
+		expired, err := rl.ExpiredFiles()
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []string{current}, expired)
+	})
+
+	t.Run("Unparsable names fall back to modification times", func(t *testing.T) {
+		// matches the naming scheme, but is not a valid date
+		invalid := filepath.Join(dir, "app-2021-13-45.log")
+		stale := filepath.Join(dir, "app-2021-00-00.log")
+		for path, mtime := range map[string]time.Time{invalid: recent, stale: early} {
+			f, err := os.Create(path)
+			if !assert.NoError(t, err) {
+				return
+			}
+			f.Close()
+			defer os.Remove(path)
+			if !assert.NoError(t, os.Chtimes(path, mtime, mtime)) {
+				return
+			}
+		}
+
+		rl := newLogger(t, dir,
+			rotatelogs.WithClock(clockwork.NewFakeClockAt(testTime)),
+			rotatelogs.WithMaxAgeDuration(6*time.Hour),
+		)
+		defer rl.Close()
+
+		expired, err := rl.ExpiredFiles()
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []string{stale}, expired)
+	})
+}
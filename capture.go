@@ -0,0 +1,215 @@
+package rotatelogs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// Stream selects the standard streams redirected by CaptureOutput.
+type Stream int
+
+const (
+	Stdout Stream = 1 << iota
+	Stderr
+)
+
+// CaptureOption configures CaptureOutput.
+type CaptureOption func(*captureConfig)
+
+type captureConfig struct {
+	tee bool
+}
+
+// WithTee keeps writing captured output to the original stream as well,
+// e.g. so that stderr still reaches the terminal or journald.
+func WithTee(tee bool) CaptureOption {
+	return func(c *captureConfig) {
+		c.tee = tee
+	}
+}
+
+// Capture is an active redirection of the standard streams. Stop restores
+// them.
+type Capture struct {
+	mutex   sync.Mutex
+	streams []*capturedStream
+	crash   *crashOutput
+	diag    *diagOutput
+	stopped bool
+}
+
+// capturedStream is one redirected file descriptor. orig is a duplicate
+// of the descriptor as it was before the redirection.
+type capturedStream struct {
+	fd   int
+	orig *os.File
+	r    *os.File
+	done chan struct{}
+}
+
+// CaptureOutput redirects the process's stdout and/or stderr into rl at
+// the file descriptor level, so output that bypasses the log package, such
+// as that of C libraries, child processes inheriting the descriptors, or
+// the runtime printing a panic, is rotated with the rest. The original
+// descriptors are restored by Capture.Stop.
+//
+// Output goes through a pipe drained by a goroutine. When the process
+// dies, whatever is still in the pipe is lost, so when stderr is captured
+// and the program is built with Go 1.23 or later the runtime's crash
+// output is also pointed at the current file (see
+// runtime/debug.SetCrashOutput). A fatal panic may thus appear twice if
+// the pipe happened to be drained before the process exited.
+//
+// While stderr is captured, errors rl cannot return to a caller are
+// written to the original stderr rather than into the pipe, which rl
+// itself drains.
+func CaptureOutput(rl *RotateLogs, streams Stream, options ...CaptureOption) (*Capture, error) {
+	var cfg captureConfig
+	for _, o := range options {
+		o(&cfg)
+	}
+
+	c := &Capture{}
+	for _, s := range []struct {
+		stream Stream
+		fd     int
+	}{{Stdout, 1}, {Stderr, 2}} {
+		if streams&s.stream == 0 {
+			continue
+		}
+		cs, err := redirect(s.fd)
+		if err != nil {
+			c.Stop()
+			return nil, err
+		}
+		var tee io.Writer
+		if cfg.tee {
+			tee = cs.orig
+		}
+		go cs.drain(rl, tee)
+		c.streams = append(c.streams, cs)
+		if s.fd == 2 {
+			c.diag = rl.diag
+			c.diag.set(cs.orig)
+		}
+	}
+	if streams&Stderr != 0 {
+		co, err := followCrashOutput(rl)
+		if err != nil {
+			c.Stop()
+			return nil, err
+		}
+		c.crash = co
+	}
+	return c, nil
+}
+
+// drain copies the pipe to w and, if not nil, to tee. The two are written
+// independently, so that a failing log file does not starve the original
+// stream and vice versa.
+func (cs *capturedStream) drain(w, tee io.Writer) {
+	defer close(cs.done)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := cs.r.Read(buf)
+		if n > 0 {
+			// a failing destination must not block the writers of the
+			// pipe, so errors are dropped and reading goes on
+			w.Write(buf[:n])
+			if tee != nil {
+				tee.Write(buf[:n])
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Stop restores the original stdout and stderr and waits until the
+// output captured so far has been written to the log file.
+func (c *Capture) Stop() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.stopped {
+		return nil
+	}
+	c.stopped = true
+
+	if c.diag != nil {
+		c.diag.set(nil)
+	}
+	var first error
+	for _, cs := range c.streams {
+		// replacing the descriptor closes the write end of the pipe, so
+		// drain sees EOF once the buffered output has been read
+		if err := dup2(int(cs.orig.Fd()), cs.fd); err != nil && first == nil {
+			first = errors.Wrapf(err, "failed to restore fd %d", cs.fd)
+		}
+		<-cs.done
+		cs.r.Close()
+		cs.orig.Close()
+	}
+	if c.crash != nil {
+		c.crash.stop()
+	}
+	return first
+}
+
+// redirect points fd at the write end of a new pipe.
+func redirect(fd int) (*capturedStream, error) {
+	origFd, err := dup(fd)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to duplicate fd %d", fd)
+	}
+	orig := os.NewFile(uintptr(origFd), "")
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		orig.Close()
+		return nil, errors.Wrap(err, "failed to create pipe")
+	}
+	defer w.Close()
+	if err := dup2(int(w.Fd()), fd); err != nil {
+		r.Close()
+		orig.Close()
+		return nil, errors.Wrapf(err, "failed to redirect fd %d", fd)
+	}
+	return &capturedStream{fd: fd, orig: orig, r: r, done: make(chan struct{})}, nil
+}
+
+// diagOutput is where a RotateLogs object reports the errors it cannot
+// return to a caller. It is os.Stderr unless stderr is captured into the
+// object, in which case writing to it while holding the object's lock
+// could block on the pipe only the object itself drains.
+type diagOutput struct {
+	w atomic.Value // diagWriter
+}
+
+type diagWriter struct {
+	io.Writer
+}
+
+// set redirects the diagnostics to w, or back to os.Stderr if w is nil
+func (d *diagOutput) set(w io.Writer) {
+	d.w.Store(diagWriter{w})
+}
+
+func (d *diagOutput) writer() io.Writer {
+	if d == nil {
+		return os.Stderr
+	}
+	if w, ok := d.w.Load().(diagWriter); ok && w.Writer != nil {
+		return w.Writer
+	}
+	return os.Stderr
+}
+
+func (d *diagOutput) printf(format string, args ...interface{}) {
+	fmt.Fprintf(d.writer(), format, args...)
+}
@@ -0,0 +1,14 @@
+//go:build darwin || freebsd
+// +build darwin freebsd
+
+package rotatelogs
+
+import "syscall"
+
+func dup(fd int) (int, error) {
+	return syscall.Dup(fd)
+}
+
+func dup2(oldfd, newfd int) error {
+	return syscall.Dup2(oldfd, newfd)
+}
@@ -0,0 +1,61 @@
+//go:build go1.23
+// +build go1.23
+
+package rotatelogs
+
+import (
+	"os"
+	"runtime/debug"
+
+	"github.com/pkg/errors"
+)
+
+// crashOutput points the runtime's crash output at the current log file
+// and follows it across rotations.
+type crashOutput struct {
+	cancel func()
+	done   chan struct{}
+}
+
+func followCrashOutput(rl *RotateLogs) (*crashOutput, error) {
+	rl.mutex.Lock()
+	_, err := rl.getWriterNolock(false, false)
+	filename := rl.curFn
+	rl.mutex.Unlock()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open the current log file")
+	}
+	if err := setCrashOutput(filename); err != nil {
+		return nil, err
+	}
+
+	events, cancel := rl.Subscribe(16)
+	co := &crashOutput{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(co.done)
+		for e := range events {
+			if ev, ok := e.(*FileRotatedEvent); ok {
+				if err := setCrashOutput(ev.CurrentFile()); err != nil {
+					rl.diag.printf("%s\n", err.Error())
+				}
+			}
+		}
+	}()
+	return co, nil
+}
+
+func (co *crashOutput) stop() {
+	co.cancel()
+	<-co.done
+	debug.SetCrashOutput(nil, debug.CrashOptions{})
+}
+
+func setCrashOutput(filename string) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return errors.Wrap(err, "failed to open crash output")
+	}
+	// SetCrashOutput keeps its own duplicate of the descriptor
+	defer f.Close()
+	return errors.Wrap(debug.SetCrashOutput(f, debug.CrashOptions{}), "failed to set crash output")
+}
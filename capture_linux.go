@@ -0,0 +1,13 @@
+package rotatelogs
+
+import "syscall"
+
+func dup(fd int) (int, error) {
+	return syscall.Dup(fd)
+}
+
+// dup2 uses Dup3, since some architectures such as arm64 have no dup2
+// system call.
+func dup2(oldfd, newfd int) error {
+	return syscall.Dup3(oldfd, newfd, 0)
+}
@@ -0,0 +1,15 @@
+//go:build !go1.23
+// +build !go1.23
+
+package rotatelogs
+
+// crashOutput is a no-op before Go 1.23, which added
+// runtime/debug.SetCrashOutput. A fatal panic then only reaches the log
+// file if the pipe is drained before the process exits.
+type crashOutput struct{}
+
+func followCrashOutput(rl *RotateLogs) (*crashOutput, error) {
+	return nil, nil
+}
+
+func (co *crashOutput) stop() {}
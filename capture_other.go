@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package rotatelogs
+
+import "github.com/pkg/errors"
+
+var errCaptureUnsupported = errors.New("capturing stdout and stderr is not supported on this platform")
+
+func dup(fd int) (int, error) {
+	return -1, errCaptureUnsupported
+}
+
+func dup2(oldfd, newfd int) error {
+	return errCaptureUnsupported
+}
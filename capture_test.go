@@ -0,0 +1,181 @@
+//go:build linux
+// +build linux
+
+package rotatelogs_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+// redirectFd points fd at a new file in dir, so that what the capture
+// passes on to the original stream can be inspected. The returned
+// function restores fd.
+func redirectFd(t *testing.T, fd int, path string) func() {
+	f, err := os.Create(path)
+	if !assert.NoError(t, err, "creating %s should succeed", path) {
+		t.FailNow()
+	}
+	defer f.Close()
+	saved, err := syscall.Dup(fd)
+	if !assert.NoError(t, err, "duplicating fd %d should succeed", fd) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, syscall.Dup3(int(f.Fd()), fd, 0), "redirecting fd %d should succeed", fd) {
+		syscall.Close(saved)
+		t.FailNow()
+	}
+	return func() {
+		syscall.Dup3(saved, fd, 0)
+		syscall.Close(saved)
+	}
+}
+
+func readLog(t *testing.T, dir string) string {
+	matches, err := filepath.Glob(filepath.Join(dir, "app-*.log"))
+	if !assert.NoError(t, err) || !assert.Len(t, matches, 1, "there should be a single log file") {
+		return ""
+	}
+	content, err := ioutil.ReadFile(matches[0])
+	assert.NoError(t, err, "reading %s should succeed", matches[0])
+	return string(content)
+}
+
+func TestCaptureOutput(t *testing.T) {
+	t.Run("Stdout", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		restore := redirectFd(t, 1, filepath.Join(dir, "stdout"))
+		defer restore()
+
+		rl := newLogger(t, dir)
+		defer rl.Close()
+		c, err := rotatelogs.CaptureOutput(rl, rotatelogs.Stdout)
+		if !assert.NoError(t, err, "CaptureOutput should succeed") {
+			return
+		}
+		fmt.Fprintln(os.Stdout, "captured")
+		if !assert.NoError(t, c.Stop(), "Stop should succeed") {
+			return
+		}
+		fmt.Fprintln(os.Stdout, "restored")
+
+		assert.Equal(t, "captured\n", readLog(t, dir), "the output should be in the log file")
+		orig, err := ioutil.ReadFile(filepath.Join(dir, "stdout"))
+		if assert.NoError(t, err) {
+			assert.Equal(t, "restored\n", string(orig), "stdout should be restored by Stop")
+		}
+	})
+
+	t.Run("TeeWithFailingLog", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		restore := redirectFd(t, 1, filepath.Join(dir, "stdout"))
+		defer restore()
+
+		rl := newLogger(t, dir)
+		rl.Write([]byte("before\n"))
+		// writes fail from now on
+		rl.Close()
+		c, err := rotatelogs.CaptureOutput(rl, rotatelogs.Stdout, rotatelogs.WithTee(true))
+		if !assert.NoError(t, err, "CaptureOutput should succeed") {
+			return
+		}
+		fmt.Fprintln(os.Stdout, "teed")
+		if !assert.NoError(t, c.Stop(), "Stop should succeed") {
+			return
+		}
+
+		orig, err := ioutil.ReadFile(filepath.Join(dir, "stdout"))
+		if assert.NoError(t, err) {
+			assert.Equal(t, "teed\n", string(orig), "the original stream should get the output although the log fails")
+		}
+	})
+
+	t.Run("Diagnostics", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		restore := redirectFd(t, 2, filepath.Join(dir, "stderr"))
+		defer restore()
+
+		// with SyncDelivery the panic is reported while rl holds its lock
+		rl := newLogger(t, dir, rotatelogs.WithHandler(rotatelogs.HandlerFunc(func(e rotatelogs.Event) {
+			if e.Type() == rotatelogs.FileRotatedEventType {
+				panic("handler failure")
+			}
+		})))
+		defer rl.Close()
+		c, err := rotatelogs.CaptureOutput(rl, rotatelogs.Stderr)
+		if !assert.NoError(t, err, "CaptureOutput should succeed") {
+			return
+		}
+		fmt.Fprintln(os.Stderr, "captured")
+		rl.Rotate()
+		if !assert.NoError(t, c.Stop(), "Stop should succeed") {
+			return
+		}
+
+		orig, err := ioutil.ReadFile(filepath.Join(dir, "stderr"))
+		if assert.NoError(t, err) {
+			assert.Contains(t, string(orig), "handler failure", "diagnostics should go to the original stderr")
+		}
+		logs, err := filepath.Glob(filepath.Join(dir, "app-*.log*"))
+		if !assert.NoError(t, err) {
+			return
+		}
+		var all string
+		for _, path := range logs {
+			content, _ := ioutil.ReadFile(path)
+			all += string(content)
+		}
+		assert.Contains(t, all, "captured", "the captured output should be logged")
+		assert.NotContains(t, all, "handler failure", "diagnostics should not be written into the log")
+	})
+
+	t.Run("Maintenance errors", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		restore := redirectFd(t, 2, filepath.Join(dir, "stderr"))
+		defer restore()
+
+		// a file in place of the trash directory makes retention fail
+		old := testTime.AddDate(0, 0, -3)
+		writeLogFile(t, dir, old, "expired\n", old)
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, ".trash"), nil, 0644)) {
+			return
+		}
+		rl := newLogger(t, dir,
+			rotatelogs.WithClock(clockwork.NewFakeClockAt(testTime)),
+			rotatelogs.WithMaxAge(1),
+			rotatelogs.WithTrashGracePeriod(time.Hour),
+		)
+		defer rl.Close()
+		rl.Write([]byte("current\n"))
+		c, err := rotatelogs.CaptureOutput(rl, rotatelogs.Stdout)
+		if !assert.NoError(t, err, "CaptureOutput should succeed") {
+			return
+		}
+		assert.Error(t, rl.RunMaintenance(), "maintenance should fail")
+		if !assert.NoError(t, c.Stop(), "Stop should succeed") {
+			return
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(dir, "app-2021-11-14.log"))
+		if assert.NoError(t, err) {
+			assert.Equal(t, "current\n", string(content), "maintenance errors should not be written into the log")
+		}
+		orig, err := ioutil.ReadFile(filepath.Join(dir, "stderr"))
+		if assert.NoError(t, err) {
+			assert.Contains(t, string(orig), ".trash", "maintenance errors should be reported on stderr")
+		}
+	})
+}
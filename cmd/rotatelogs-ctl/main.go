@@ -0,0 +1,520 @@
+// Command rotatelogs-ctl inspects and maintains the files of a logger
+// without running the application, using the naming and retention rules
+// of the library.
+//
+//	rotatelogs-ctl list -path /var/log/app -name app
+//	rotatelogs-ctl purge -path /var/log/app -name app -max-age 7d -dry-run
+//	rotatelogs-ctl cat -path /var/log/app -name app -from 2021-11-13 -to 2021-11-14
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+	"github.com/chriszhangmq/file-rotatelogs/internal/common"
+)
+
+// dateLayout is the layout of the dates in file names and of -from/-to.
+const dateLayout = "2006-01-02"
+
+type command struct {
+	summary string
+	flags   func(fs *flag.FlagSet) func(*rotatelogs.Config) int
+}
+
+var commands = map[string]command{
+	"list":     {"list the log files", listCommand},
+	"compress": {"compress the files of previous days", compressCommand},
+	"purge":    {"delete the files older than -max-age", purgeCommand},
+	"verify":   {"check compressed files and report leftovers", verifyCommand},
+	"cat":      {"print the logs of a date range", catCommand},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: rotatelogs-ctl <command> [flags]\n\ncommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-9s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nrun rotatelogs-ctl <command> -h for the flags of a command\n")
+}
+
+func run(args []string) int {
+	if len(args) == 0 {
+		usage()
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		if args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+			usage()
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "rotatelogs-ctl: unknown command %q\n", args[0])
+		usage()
+		return 2
+	}
+
+	fs := flag.NewFlagSet("rotatelogs-ctl "+args[0], flag.ContinueOnError)
+	var cfg rotatelogs.Config
+	var configFile, envPrefix string
+	fs.StringVar(&configFile, "config", "", "JSON configuration `file` of the logger, overridden by the environment and flags")
+	fs.StringVar(&envPrefix, "env-prefix", "ROTATELOGS", "`prefix` of the environment variables overlaying the configuration")
+	fs.StringVar(&cfg.FilePath, "path", "", "`directory` of the log files")
+	fs.StringVar(&cfg.FileName, "name", "", "base `name` of the log files")
+	fs.StringVar(&cfg.Location, "location", "", "time `zone` of the dates in file names (default local time)")
+	fs.Var(&cfg.MaxAge, "max-age", "files older than `duration` are expired, e.g. 36h or 7d")
+	fs.StringVar(&cfg.AgeSource, "age-source", "", "take the age of files from their \"name\" or \"mtime\"")
+	fs.Var(&cfg.TrashGracePeriod, "trash-grace", "move expired files to .trash and remove them after `duration`")
+	execute := cmd.flags(fs)
+
+	if err := fs.Parse(args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if err := loadConfig(fs, &cfg, configFile, envPrefix); err != nil {
+		fmt.Fprintf(os.Stderr, "rotatelogs-ctl: %v\n", err)
+		return 2
+	}
+	return execute(&cfg)
+}
+
+// loadConfig combines, in increasing precedence, the configuration file,
+// the environment and the flags given on the command line.
+func loadConfig(fs *flag.FlagSet, cfg *rotatelogs.Config, file, envPrefix string) error {
+	set := map[string]string{}
+	fs.Visit(func(fl *flag.Flag) {
+		set[fl.Name] = fl.Value.String()
+	})
+	if file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+	}
+	if err := cfg.LoadEnv(envPrefix); err != nil {
+		return err
+	}
+	for name, value := range set {
+		if err := fs.Set(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// open creates the logger described by cfg. Its events are printed as
+// they happen, and failures are counted in failures. The logger never
+// runs the commands or uploads of the configuration.
+func open(cfg *rotatelogs.Config, failures *int, options ...rotatelogs.Option) (*rotatelogs.RotateLogs, error) {
+	offline := *cfg
+	offline.CronTime = ""
+	offline.PostRotate = nil
+	offline.Shipper = nil
+	return rotatelogs.NewFromConfig(offline, append([]rotatelogs.Option{
+		rotatelogs.WithEventDelivery(rotatelogs.SyncDelivery),
+		rotatelogs.WithHandler(rotatelogs.HandlerFunc(func(e rotatelogs.Event) {
+			switch ev := e.(type) {
+			case *rotatelogs.FileCompressedEvent:
+				fmt.Printf("compressed %s (%d -> %d bytes)\n", ev.SourceFile(), ev.OriginalSize(), ev.CompressedSize())
+			case *rotatelogs.FileDeletedEvent:
+				if ev.Quarantined() {
+					fmt.Printf("quarantined %s\n", ev.File())
+				} else {
+					fmt.Printf("deleted %s\n", ev.File())
+				}
+			case *rotatelogs.CompressionFailedEvent:
+				fmt.Fprintf(os.Stderr, "failed to compress %s: %v\n", ev.File(), ev.Err())
+				*failures++
+			case *rotatelogs.MaintenanceCompletedEvent:
+				if ev.Err() != nil {
+					fmt.Fprintf(os.Stderr, "rotatelogs-ctl: %v\n", ev.Err())
+					*failures++
+				}
+			}
+		})),
+	}, options...)...)
+}
+
+// logDir returns the directory of the log files and the base name of the
+// logger, from which the names of its sidecar files are derived.
+func logDir(cfg *rotatelogs.Config) (string, string) {
+	base := filepath.Join(cfg.FilePath, cfg.FileName)
+	return filepath.Dir(base), filepath.Base(base)
+}
+
+// journalHook vetoes the deletion of the files the logger's shipper has
+// not shipped yet, as the running logger would. The journal is only read,
+// the offline tools never upload.
+type journalHook struct {
+	pending map[string]bool // base names of the files waiting for upload
+	// the logger ships compressed files only, so an uncompressed file
+	// has not been shipped yet
+	compressed bool
+}
+
+func newJournalHook(cfg *rotatelogs.Config) (*journalHook, error) {
+	dir, name := logDir(cfg)
+	h := &journalHook{
+		pending:    make(map[string]bool),
+		compressed: cfg.Shipper != nil && cfg.Compress,
+	}
+	journal := filepath.Join(dir, "."+name+common.ShipperJournalSuffix)
+	data, err := ioutil.ReadFile(journal)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return h, nil
+		}
+		return nil, err
+	}
+	var entries []rotatelogs.Shipment
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("%s: %v", journal, err)
+	}
+	for _, entry := range entries {
+		if !entry.GaveUp {
+			h.pending[filepath.Base(entry.Path)] = true
+		}
+	}
+	return h, nil
+}
+
+func (h *journalHook) BeforeDelete(path string) error {
+	if h.pending[filepath.Base(path)] {
+		return fmt.Errorf("%s has not been shipped yet", path)
+	}
+	if h.compressed && !strings.HasSuffix(path, common.CompressSuffix) {
+		return fmt.Errorf("%s has not been compressed and shipped yet", path)
+	}
+	return nil
+}
+
+func listCommand(fs *flag.FlagSet) func(*rotatelogs.Config) int {
+	asJSON := fs.Bool("json", false, "print the files as JSON")
+	return func(cfg *rotatelogs.Config) int {
+		var failures int
+		rl, err := open(cfg, &failures)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rotatelogs-ctl: %v\n", err)
+			return 2
+		}
+		defer rl.Close()
+
+		files, err := rl.Files()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rotatelogs-ctl: %v\n", err)
+			return 1
+		}
+		if *asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(files); err != nil {
+				fmt.Fprintf(os.Stderr, "rotatelogs-ctl: %v\n", err)
+				return 1
+			}
+			return 0
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "DATE\tPART\tSIZE\tMODIFIED\tFLAGS\tFILE")
+		for _, f := range files {
+			date := "invalid"
+			if !f.Date.IsZero() {
+				date = f.Date.Format(dateLayout)
+			}
+			var flags []string
+			if f.Compressed {
+				flags = append(flags, "gz")
+			}
+			if f.Pinned {
+				flags = append(flags, "pinned")
+			}
+			if len(flags) == 0 {
+				flags = append(flags, "-")
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\n", date, f.Part, f.Size,
+				f.ModTime.Format(time.RFC3339), strings.Join(flags, ","), f.Path)
+		}
+		tw.Flush()
+		return 0
+	}
+}
+
+func compressCommand(fs *flag.FlagSet) func(*rotatelogs.Config) int {
+	return func(cfg *rotatelogs.Config) int {
+		// compression only, retention is left to purge
+		cfg.Compress = true
+		cfg.MaxAge = 0
+		cfg.TrashGracePeriod = 0
+
+		var failures int
+		rl, err := open(cfg, &failures)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rotatelogs-ctl: %v\n", err)
+			return 2
+		}
+		rl.RunMaintenance()
+		rl.Close()
+		if failures > 0 {
+			return 1
+		}
+		return 0
+	}
+}
+
+func purgeCommand(fs *flag.FlagSet) func(*rotatelogs.Config) int {
+	dryRun := fs.Bool("dry-run", false, "only print the files that would be deleted or quarantined")
+	return func(cfg *rotatelogs.Config) int {
+		if cfg.MaxAge <= 0 {
+			fmt.Fprintf(os.Stderr, "rotatelogs-ctl: purge needs -max-age\n")
+			return 2
+		}
+		hook, err := newJournalHook(cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rotatelogs-ctl: failed to read the shipper journal: %v\n", err)
+			return 1
+		}
+		cfg.Compress = false
+
+		var failures int
+		rl, err := open(cfg, &failures, rotatelogs.WithDeleteHook(hook))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rotatelogs-ctl: %v\n", err)
+			return 2
+		}
+		defer rl.Close()
+
+		// the dry run lists what RunRetention works on, and in the words
+		// the real run prints
+		if *dryRun {
+			files, err := rl.ExpiredFiles()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "rotatelogs-ctl: %v\n", err)
+				return 1
+			}
+			for _, path := range files {
+				if err := hook.BeforeDelete(path); err != nil {
+					fmt.Printf("would keep %s: %v\n", path, err)
+				} else if cfg.TrashGracePeriod > 0 {
+					fmt.Printf("would quarantine %s\n", path)
+				} else {
+					fmt.Printf("would delete %s\n", path)
+				}
+			}
+			if cfg.TrashGracePeriod > 0 {
+				trash, err := rl.ExpiredTrash()
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "rotatelogs-ctl: %v\n", err)
+					return 1
+				}
+				for _, f := range trash {
+					fmt.Printf("would delete %s\n", f.Path)
+				}
+			}
+			return 0
+		}
+		rl.RunRetention()
+		if failures > 0 {
+			return 1
+		}
+		return 0
+	}
+}
+
+func verifyCommand(fs *flag.FlagSet) func(*rotatelogs.Config) int {
+	return func(cfg *rotatelogs.Config) int {
+		var failures int
+		rl, err := open(cfg, &failures)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rotatelogs-ctl: %v\n", err)
+			return 2
+		}
+		defer rl.Close()
+
+		files, err := rl.Files()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rotatelogs-ctl: %v\n", err)
+			return 1
+		}
+		problems := 0
+		report := func(format string, args ...interface{}) {
+			fmt.Printf(format+"\n", args...)
+			problems++
+		}
+
+		plain := make(map[string]bool, len(files))
+		for _, f := range files {
+			if !f.Compressed {
+				plain[f.Path] = true
+			}
+		}
+		for _, f := range files {
+			if f.Date.IsZero() {
+				report("%s: the date in the file name is invalid", f.Path)
+			}
+			if !f.Compressed {
+				continue
+			}
+			if plain[strings.TrimSuffix(f.Path, ".gz")] {
+				report("%s: the uncompressed file still exists, compression was interrupted", f.Path)
+			}
+			if err := checkGzip(f.Path); err != nil {
+				report("%s: %v", f.Path, err)
+			}
+		}
+
+		pinned, err := rl.Pinned()
+		if err != nil {
+			report("failed to read the pins: %v", err)
+		}
+		dir, _ := logDir(cfg)
+		for _, name := range pinned {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err != nil {
+				if _, err := os.Stat(path + ".gz"); err != nil {
+					report("%s: pinned but missing", path)
+				}
+			}
+		}
+
+		if problems > 0 {
+			fmt.Printf("%d problem(s) in %d file(s)\n", problems, len(files))
+			return 1
+		}
+		fmt.Printf("ok: %d file(s)\n", len(files))
+		return 0
+	}
+}
+
+// checkGzip reads a compressed file to the end, which verifies its
+// checksum.
+func checkGzip(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("corrupt: %v", err)
+	}
+	if _, err := io.Copy(ioutil.Discard, zr); err != nil {
+		return fmt.Errorf("corrupt: %v", err)
+	}
+	return nil
+}
+
+func catCommand(fs *flag.FlagSet) func(*rotatelogs.Config) int {
+	from := fs.String("from", "", "first `date` to print, as YYYY-MM-DD (default: the oldest file)")
+	to := fs.String("to", "", "last `date` to print, as YYYY-MM-DD (default: the newest file)")
+	return func(cfg *rotatelogs.Config) int {
+		loc := time.Local
+		if cfg.Location != "" {
+			var err error
+			if loc, err = time.LoadLocation(cfg.Location); err != nil {
+				fmt.Fprintf(os.Stderr, "rotatelogs-ctl: %v\n", err)
+				return 2
+			}
+		}
+		var first, last time.Time
+		for _, d := range []struct {
+			flag  string
+			value string
+			t     *time.Time
+		}{{"-from", *from, &first}, {"-to", *to, &last}} {
+			if d.value == "" {
+				continue
+			}
+			t, err := time.ParseInLocation(dateLayout, d.value, loc)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "rotatelogs-ctl: invalid %s date %q\n", d.flag, d.value)
+				return 2
+			}
+			*d.t = t
+		}
+
+		var failures int
+		rl, err := open(cfg, &failures)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rotatelogs-ctl: %v\n", err)
+			return 2
+		}
+		defer rl.Close()
+
+		files, err := rl.Files()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rotatelogs-ctl: %v\n", err)
+			return 1
+		}
+		plain := make(map[string]bool, len(files))
+		for _, f := range files {
+			if !f.Compressed {
+				plain[f.Path] = true
+			}
+		}
+
+		out := bufio.NewWriter(os.Stdout)
+		defer out.Flush()
+		status := 0
+		for _, f := range files {
+			if f.Date.IsZero() || (!first.IsZero() && f.Date.Before(first)) || (!last.IsZero() && f.Date.After(last)) {
+				continue
+			}
+			// an interrupted compression leaves both files, the
+			// uncompressed one is complete
+			if f.Compressed && plain[strings.TrimSuffix(f.Path, ".gz")] {
+				continue
+			}
+			if err := copyFile(out, f); err != nil {
+				fmt.Fprintf(os.Stderr, "rotatelogs-ctl: %s: %v\n", f.Path, err)
+				status = 1
+			}
+		}
+		return status
+	}
+}
+
+func copyFile(w io.Writer, f rotatelogs.LogFile) error {
+	fh, err := os.Open(f.Path)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var r io.Reader = fh
+	if f.Compressed {
+		zr, err := gzip.NewReader(fh)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
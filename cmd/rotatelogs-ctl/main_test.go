@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+	"github.com/stretchr/testify/assert"
+)
+
+// capture runs the command given by args with its standard output
+// redirected, and returns the exit status and the output.
+func capture(t *testing.T, args ...string) (int, string) {
+	r, w, err := os.Pipe()
+	if !assert.NoError(t, err, "creating a pipe should succeed") {
+		t.FailNow()
+	}
+	defer r.Close()
+	done := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		done <- buf.String()
+	}()
+
+	stdout := os.Stdout
+	os.Stdout = w
+	status := run(args)
+	os.Stdout = stdout
+	w.Close()
+	return status, <-done
+}
+
+// writeFiles creates the files of contents in dir, compressing the ones
+// whose name ends in .gz.
+func writeFiles(t *testing.T, dir string, contents map[string]string) {
+	for name, content := range contents {
+		data := []byte(content)
+		if strings.HasSuffix(name, ".gz") {
+			var buf bytes.Buffer
+			zw := gzip.NewWriter(&buf)
+			zw.Write(data)
+			zw.Close()
+			data = buf.Bytes()
+		}
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), data, 0644)) {
+			t.FailNow()
+		}
+	}
+}
+
+func TestMaintenanceCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-rotatelogs-ctl")
+	if !assert.NoError(t, err, "creating temporary directory should succeed") {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"app-2021-11-13.log", "app-2021-11-14.log", "app-2021-11-14.log.1.log", "app-api-2021-11-13.log"} {
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(name+"\n"), 0644)) {
+			return
+		}
+	}
+	common := []string{"-path", dir, "-name", "app"}
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+
+	assert.Equal(t, 0, run(append([]string{"compress"}, common...)), "compress should succeed")
+	assert.True(t, exists("app-2021-11-13.log.gz"))
+	assert.True(t, exists("app-2021-11-14.log.1.log.gz"))
+	assert.False(t, exists("app-2021-11-14.log"))
+	assert.True(t, exists("app-api-2021-11-13.log"), "files of other loggers should be left alone")
+
+	assert.Equal(t, 0, run(append([]string{"verify"}, common...)), "verify should find no problems")
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "app-2021-11-14.log.gz"), []byte("garbage"), 0644)) {
+		return
+	}
+	assert.Equal(t, 1, run(append([]string{"verify"}, common...)), "verify should report the corrupt file")
+
+	assert.Equal(t, 2, run(append([]string{"purge"}, common...)), "purge without -max-age should be rejected")
+	assert.Equal(t, 0, run(append([]string{"purge", "-max-age", "1d", "-dry-run"}, common...)), "purge -dry-run should succeed")
+	assert.True(t, exists("app-2021-11-13.log.gz"), "purge -dry-run should not delete files")
+	assert.Equal(t, 0, run(append([]string{"purge", "-max-age", "1d"}, common...)), "purge should succeed")
+	assert.False(t, exists("app-2021-11-13.log.gz"))
+	assert.False(t, exists("app-2021-11-14.log.1.log.gz"))
+	assert.True(t, exists("app-api-2021-11-13.log"), "files of other loggers should be left alone")
+}
+
+func TestVerifyPins(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-rotatelogs-ctl")
+	if !assert.NoError(t, err, "creating temporary directory should succeed") {
+		return
+	}
+	defer os.RemoveAll(dir)
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "app-2021-11-13.log"), []byte("kept\n"), 0644)) {
+		return
+	}
+	pins := filepath.Join(dir, ".app.pins")
+	if !assert.NoError(t, ioutil.WriteFile(pins, []byte(`{"files":["app-2021-11-13.log"]}`), 0644)) {
+		return
+	}
+
+	// pinned names are relative to the log directory, not to the
+	// working directory
+	wd, err := os.Getwd()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.Chdir(wd)
+	if !assert.NoError(t, os.Chdir(os.TempDir())) {
+		return
+	}
+	common := []string{"-path", dir, "-name", "app"}
+	assert.Equal(t, 0, run(append([]string{"verify"}, common...)), "verify should find the pinned file")
+
+	if !assert.NoError(t, ioutil.WriteFile(pins, []byte(`{"files":["app-2021-11-12.log","app-2021-11-13.log"]}`), 0644)) {
+		return
+	}
+	assert.Equal(t, 1, run(append([]string{"verify"}, common...)), "verify should report the missing pinned file")
+}
+
+func TestPurgeShipperJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-rotatelogs-ctl")
+	if !assert.NoError(t, err, "creating temporary directory should succeed") {
+		return
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"app-2021-11-11.log", "app-2021-11-12.log.gz", "app-2021-11-13.log.gz"} {
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(name+"\n"), 0644)) {
+			return
+		}
+	}
+	journal := `[
+		{"path": "` + filepath.Join(dir, "app-2021-11-12.log.gz") + `", "key": "app-2021-11-12.log.gz", "attempts": 3, "gave_up": true},
+		{"path": "` + filepath.Join(dir, "app-2021-11-13.log.gz") + `", "key": "app-2021-11-13.log.gz", "attempts": 1}
+	]`
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, ".app.shipper.json"), []byte(journal), 0644)) {
+		return
+	}
+	config := filepath.Join(dir, "config.json")
+	data := `{"compress":true,"shipper":{"dir":"` + filepath.Join(dir, "archive") + `"}}`
+	if !assert.NoError(t, ioutil.WriteFile(config, []byte(data), 0644)) {
+		return
+	}
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+
+	assert.Equal(t, 0, run([]string{"purge", "-config", config, "-path", dir, "-name", "app", "-max-age", "1d"}), "purge should succeed")
+	assert.False(t, exists("app-2021-11-12.log.gz"), "files the shipper gave up on should be purged")
+	assert.True(t, exists("app-2021-11-13.log.gz"), "files waiting in the journal should be kept")
+	assert.True(t, exists("app-2021-11-11.log"), "uncompressed files should be kept until they are compressed and shipped")
+
+	assert.Equal(t, 0, run([]string{"purge", "-path", dir, "-name", "app", "-max-age", "1d"}), "purge should succeed")
+	assert.False(t, exists("app-2021-11-11.log"), "without compression the journal alone decides")
+	assert.True(t, exists("app-2021-11-13.log.gz"), "files waiting in the journal should be kept")
+}
+
+func TestPurgeDryRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-rotatelogs-ctl")
+	if !assert.NoError(t, err, "creating temporary directory should succeed") {
+		return
+	}
+	defer os.RemoveAll(dir)
+	// an interrupted compression of today leaves both copies, which is
+	// neither expired nor for purge to clean up
+	today := "app-" + time.Now().Format(dateLayout) + ".log"
+	writeFiles(t, dir, map[string]string{
+		"app-2021-11-12.log.gz": "twelve\n",
+		"app-2021-11-13.log":    "thirteen\n",
+		today:                   "today\n",
+		today + ".gz":           "today\n",
+	})
+	common := []string{"-path", dir, "-name", "app", "-max-age", "1d"}
+
+	status, planned := capture(t, append([]string{"purge", "-dry-run"}, common...)...)
+	assert.Equal(t, 0, status, "purge -dry-run should succeed")
+	status, done := capture(t, append([]string{"purge"}, common...)...)
+	assert.Equal(t, 0, status, "purge should succeed")
+
+	lines := func(out string) []string {
+		l := strings.Split(strings.TrimSpace(out), "\n")
+		sort.Strings(l)
+		return l
+	}
+	assert.Equal(t, []string{
+		"would delete " + filepath.Join(dir, "app-2021-11-12.log.gz"),
+		"would delete " + filepath.Join(dir, "app-2021-11-13.log"),
+	}, lines(planned))
+	assert.Equal(t, strings.Replace(strings.Join(lines(planned), "\n"), "would delete", "deleted", -1),
+		strings.Join(lines(done), "\n"), "purge should delete what the dry run lists")
+	for _, name := range []string{today, today + ".gz"} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		assert.NoError(t, err, "purge should leave %s alone", name)
+	}
+}
+
+func TestList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-rotatelogs-ctl")
+	if !assert.NoError(t, err, "creating temporary directory should succeed") {
+		return
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"app-2021-11-13.log.gz":    "thirteen\n",
+		"app-2021-11-14.log":       "fourteen\n",
+		"app-2021-11-14.log.1.log": "fourteen again\n",
+		"app-api-2021-11-13.log":   "other\n",
+	})
+	common := []string{"-path", dir, "-name", "app"}
+
+	t.Run("Table", func(t *testing.T) {
+		status, out := capture(t, append([]string{"list"}, common...)...)
+		assert.Equal(t, 0, status, "list should succeed")
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		if !assert.Len(t, lines, 4, "list should print a header and a line per file") {
+			return
+		}
+		assert.Equal(t, []string{"DATE", "PART", "SIZE", "MODIFIED", "FLAGS", "FILE"}, strings.Fields(lines[0]))
+		for i, want := range []struct {
+			date, part, flags, name string
+		}{
+			{"2021-11-13", "0", "gz", "app-2021-11-13.log.gz"},
+			{"2021-11-14", "0", "-", "app-2021-11-14.log"},
+			{"2021-11-14", "1", "-", "app-2021-11-14.log.1.log"},
+		} {
+			fields := strings.Fields(lines[i+1])
+			if assert.Len(t, fields, 6) {
+				assert.Equal(t, []string{want.date, want.part}, fields[:2])
+				assert.Equal(t, []string{want.flags, filepath.Join(dir, want.name)}, fields[4:])
+			}
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		status, out := capture(t, append([]string{"list", "-json"}, common...)...)
+		assert.Equal(t, 0, status, "list -json should succeed")
+		var files []rotatelogs.LogFile
+		if !assert.NoError(t, json.Unmarshal([]byte(out), &files), "the output should be JSON") {
+			return
+		}
+		if assert.Len(t, files, 3) {
+			assert.Equal(t, filepath.Join(dir, "app-2021-11-13.log.gz"), files[0].Path)
+			assert.True(t, files[0].Compressed)
+			assert.Equal(t, 1, files[2].Part)
+			assert.EqualValues(t, len("fourteen again\n"), files[2].Size)
+		}
+	})
+}
+
+func TestCat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-rotatelogs-ctl")
+	if !assert.NoError(t, err, "creating temporary directory should succeed") {
+		return
+	}
+	defer os.RemoveAll(dir)
+	writeFiles(t, dir, map[string]string{
+		"app-2021-11-12.log.gz":    "twelve\n",
+		"app-2021-11-13.log":       "thirteen\n",
+		"app-2021-11-13.log.gz":    "partial",
+		"app-2021-11-14.log":       "fourteen\n",
+		"app-2021-11-14.log.1.log": "fourteen again\n",
+		"app-2021-11-15.log":       "fifteen\n",
+	})
+	common := []string{"-path", dir, "-name", "app"}
+
+	for _, tc := range []struct {
+		name  string
+		flags []string
+		want  string
+	}{
+		{"All", nil, "twelve\nthirteen\nfourteen\nfourteen again\nfifteen\n"},
+		{"From", []string{"-from", "2021-11-14"}, "fourteen\nfourteen again\nfifteen\n"},
+		{"To", []string{"-to", "2021-11-12"}, "twelve\n"},
+		// the uncompressed copy of an interrupted compression is the
+		// complete one
+		{"Range", []string{"-from", "2021-11-13", "-to", "2021-11-14"}, "thirteen\nfourteen\nfourteen again\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			status, out := capture(t, append(append([]string{"cat"}, common...), tc.flags...)...)
+			assert.Equal(t, 0, status, "cat should succeed")
+			assert.Equal(t, tc.want, out)
+		})
+	}
+
+	t.Run("Invalid date", func(t *testing.T) {
+		status, out := capture(t, append([]string{"cat", "-from", "13.11.2021"}, common...)...)
+		assert.Equal(t, 2, status, "an invalid date should be rejected")
+		assert.Empty(t, out)
+	})
+}
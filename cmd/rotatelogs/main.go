@@ -0,0 +1,385 @@
+// Command rotatelogs writes its standard input, or the output of a
+// command it runs, to rotating log files.
+//
+//	some-server | rotatelogs -path /var/log/app -name app -max-age 7d
+//	rotatelogs -path /var/log/app -name app -- some-server --port 8080
+//
+// SIGHUP rotates the file. On EOF, SIGINT or SIGTERM the file is synced
+// and closed before exiting. In wrapper mode SIGINT and SIGTERM are
+// forwarded to the command instead, and rotatelogs exits with its status.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+)
+
+// drainGrace bounds how long the output of a wrapped command is still
+// read after it exited, in case a process it spawned holds the pipe.
+const drainGrace = time.Second
+
+// maxLineLength bounds the memory held for a line whose newline has not
+// been read yet.
+const maxLineLength = 64 * 1024
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+type flags struct {
+	cfg rotatelogs.Config
+
+	configFile string
+	envPrefix  string
+	tee        bool
+
+	postRotate        string
+	postRotateOn      string
+	postRotateTimeout rotatelogs.Duration
+	postRotateMax     int
+
+	shipDir        string
+	shipPrefix     string
+	shipAttempts   int
+	shipMinBackoff rotatelogs.Duration
+	shipMaxBackoff rotatelogs.Duration
+	shipDelete     bool
+}
+
+func newFlagSet(f *flags) *flag.FlagSet {
+	fs := flag.NewFlagSet("rotatelogs", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: rotatelogs [flags] [-- command [args...]]\n\nflags:\n")
+		fs.PrintDefaults()
+	}
+
+	fs.StringVar(&f.configFile, "config", "", "JSON configuration `file`, overridden by the environment and flags")
+	fs.StringVar(&f.envPrefix, "env-prefix", "ROTATELOGS", "`prefix` of the environment variables overlaying the configuration")
+	fs.BoolVar(&f.tee, "tee", false, "also copy the input to standard output")
+
+	c := &f.cfg
+	fs.StringVar(&c.FilePath, "path", "", "`directory` of the log files")
+	fs.StringVar(&c.FileName, "name", "", "base `name` of the log files")
+	fs.StringVar(&c.Location, "location", "", "time `zone` of the dates in file names (default local time)")
+	fs.Var(&c.MaxAge, "max-age", "remove files older than `duration`, e.g. 36h or 7d")
+	fs.StringVar(&c.AgeSource, "age-source", "", "take the age of files from their \"name\" or \"mtime\"")
+	fs.Var(&c.RotationTime, "rotation-time", "rotate every `duration`, in whole days")
+	fs.Var(&c.RotationSize, "rotation-size", "rotate when a file reaches `size`, e.g. 512MiB")
+	fs.UintVar(&c.RotationCount, "rotation-count", 0, "keep at most `n` files")
+	fs.BoolVar(&c.Compress, "compress", false, "gzip files of previous days")
+	fs.StringVar(&c.CronTime, "cron", "", "maintenance `schedule`, e.g. \"0 0 * * * *\"")
+	fs.Var(&c.TrashGracePeriod, "trash-grace", "move expired files to .trash and remove them after `duration`")
+	fs.StringVar(&c.EventDelivery, "event-delivery", "", "deliver events \"async\" or \"sync\"")
+
+	fs.StringVar(&f.postRotate, "post-rotate", "", "`command` run with each rotated or compressed file as last argument")
+	fs.StringVar(&f.postRotateOn, "post-rotate-on", "rotate", "run the post-rotate command on `events`: rotate, compress or both comma separated")
+	fs.Var(&f.postRotateTimeout, "post-rotate-timeout", "kill the post-rotate command after `duration`")
+	fs.IntVar(&f.postRotateMax, "post-rotate-max", 0, "run at most `n` post-rotate commands at once")
+
+	fs.StringVar(&f.shipDir, "ship-dir", "", "ship rotated files to `directory`")
+	fs.StringVar(&f.shipPrefix, "ship-prefix", "", "`prefix` of the shipped file names")
+	fs.IntVar(&f.shipAttempts, "ship-attempts", 0, "give up shipping a file after `n` attempts (0: never)")
+	fs.Var(&f.shipMinBackoff, "ship-min-backoff", "first shipping retry `delay`")
+	fs.Var(&f.shipMaxBackoff, "ship-max-backoff", "longest shipping retry `delay`")
+	fs.BoolVar(&f.shipDelete, "ship-delete", false, "remove files once they have been shipped")
+	return fs
+}
+
+// loadConfig combines, in increasing precedence, the configuration file,
+// the environment and the flags given on the command line.
+func loadConfig(fs *flag.FlagSet, f *flags) error {
+	set := map[string]string{}
+	fs.Visit(func(fl *flag.Flag) {
+		set[fl.Name] = fl.Value.String()
+	})
+
+	if f.configFile != "" {
+		data, err := ioutil.ReadFile(f.configFile)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &f.cfg); err != nil {
+			return fmt.Errorf("%s: %v", f.configFile, err)
+		}
+	}
+	if err := f.cfg.LoadEnv(f.envPrefix); err != nil {
+		return err
+	}
+	// the file and the environment replaced the values of the flags,
+	// set them again
+	for name, value := range set {
+		if err := fs.Set(name, value); err != nil {
+			return err
+		}
+	}
+
+	if f.postRotate != "" {
+		argv := strings.Fields(f.postRotate)
+		p := &rotatelogs.PostRotateConfig{
+			Path:          argv[0],
+			Args:          argv[1:],
+			Timeout:       f.postRotateTimeout,
+			MaxConcurrent: f.postRotateMax,
+		}
+		for _, on := range strings.Split(f.postRotateOn, ",") {
+			switch strings.TrimSpace(on) {
+			case "rotate":
+				p.OnRotate = true
+			case "compress":
+				p.OnCompress = true
+			default:
+				return fmt.Errorf("invalid -post-rotate-on event %q", on)
+			}
+		}
+		f.cfg.PostRotate = p
+	}
+	if f.shipDir != "" {
+		f.cfg.Shipper = &rotatelogs.ShipperFileConfig{
+			Dir:               f.shipDir,
+			Prefix:            f.shipPrefix,
+			MaxAttempts:       f.shipAttempts,
+			MinBackoff:        f.shipMinBackoff,
+			MaxBackoff:        f.shipMaxBackoff,
+			DeleteAfterUpload: f.shipDelete,
+		}
+	}
+	return nil
+}
+
+func run(args []string) int {
+	var f flags
+	fs := newFlagSet(&f)
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if err := loadConfig(fs, &f); err != nil {
+		fmt.Fprintf(os.Stderr, "rotatelogs: %v\n", err)
+		return 2
+	}
+
+	rl, err := rotatelogs.NewFromConfig(f.cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rotatelogs: %v\n", err)
+		return 2
+	}
+	rl.Init()
+
+	out := &output{rl: rl}
+	if f.tee {
+		out.tee = os.Stdout
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	var status int
+	if fs.NArg() > 0 {
+		status = wrap(fs.Args(), out, sigs)
+	} else {
+		status = pipe(os.Stdin, out, sigs)
+	}
+	if err := out.close(); err != nil {
+		fmt.Fprintf(os.Stderr, "rotatelogs: %v\n", err)
+		if status == 0 {
+			status = 1
+		}
+	}
+	return status
+}
+
+// output writes whole lines to the log, so that rotation never splits a
+// line between two files, and stops accepting them once closed. A line
+// longer than maxLineLength is written in pieces of that length.
+type output struct {
+	mutex   sync.Mutex
+	rl      *rotatelogs.RotateLogs
+	tee     io.Writer
+	pending []byte
+	closed  bool
+}
+
+func (o *output) write(p []byte) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.closed {
+		return
+	}
+	o.pending = append(o.pending, p...)
+	for len(o.pending) > 0 {
+		n := bytes.IndexByte(o.pending, '\n') + 1
+		if n == 0 {
+			if len(o.pending) < maxLineLength {
+				break
+			}
+			n = maxLineLength
+		}
+		o.writeNolock(o.pending[:n])
+		o.pending = o.pending[n:]
+	}
+	if len(o.pending) == 0 {
+		o.pending = nil
+	}
+}
+
+// flushNolock writes the partial line received so far, if any.
+func (o *output) flushNolock() {
+	if len(o.pending) > 0 {
+		o.writeNolock(o.pending)
+		o.pending = nil
+	}
+}
+
+func (o *output) writeNolock(line []byte) {
+	if _, err := o.rl.Write(line); err != nil {
+		fmt.Fprintf(os.Stderr, "rotatelogs: %v\n", err)
+	}
+	if o.tee != nil {
+		o.tee.Write(line)
+	}
+}
+
+func (o *output) rotate() {
+	if err := o.rl.Rotate(); err != nil {
+		fmt.Fprintf(os.Stderr, "rotatelogs: failed to rotate: %v\n", err)
+	}
+}
+
+func (o *output) close() error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.closed {
+		return nil
+	}
+	o.flushNolock()
+	o.closed = true
+	if err := o.rl.Sync(); err != nil {
+		o.rl.Close()
+		return err
+	}
+	return o.rl.Close()
+}
+
+// copyLines writes r to out until EOF or a read error, then writes the
+// last line even if it does not end with a newline.
+func copyLines(r io.Reader, out *output) {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			out.write(buf[:n])
+		}
+		if err != nil {
+			if err != io.EOF {
+				fmt.Fprintf(os.Stderr, "rotatelogs: %v\n", err)
+			}
+			out.mutex.Lock()
+			out.flushNolock()
+			out.mutex.Unlock()
+			return
+		}
+	}
+}
+
+// pipe copies r to out until EOF, SIGINT or SIGTERM.
+func pipe(r io.Reader, out *output, sigs <-chan os.Signal) int {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		copyLines(r, out)
+	}()
+
+	for {
+		select {
+		case <-done:
+			return 0
+		case sig := <-sigs:
+			if sig == syscall.SIGHUP {
+				out.rotate()
+				continue
+			}
+			return 0
+		}
+	}
+}
+
+// wrap runs argv with its standard output and error going to out, and
+// returns its exit status.
+func wrap(argv []string, out *output, sigs <-chan os.Signal) int {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rotatelogs: %v\n", err)
+		return 1
+	}
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	if err := cmd.Start(); err != nil {
+		pr.Close()
+		pw.Close()
+		fmt.Fprintf(os.Stderr, "rotatelogs: %v\n", err)
+		return 127
+	}
+	pw.Close()
+
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		copyLines(pr, out)
+	}()
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	for {
+		select {
+		case sig := <-sigs:
+			if sig == syscall.SIGHUP {
+				out.rotate()
+				continue
+			}
+			cmd.Process.Signal(sig)
+		case err := <-exited:
+			select {
+			case <-drained:
+			case <-time.After(drainGrace):
+			}
+			pr.Close()
+			return exitStatus(err)
+		}
+	}
+}
+
+// exitStatus maps the result of cmd.Wait to an exit status, following the
+// shell convention of 128+n for a command killed by signal n.
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	if ee, ok := err.(*exec.ExitError); ok {
+		if ws, ok := ee.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			return 128 + int(ws.Signal())
+		}
+		return ee.ExitCode()
+	}
+	fmt.Fprintf(os.Stderr, "rotatelogs: %v\n", err)
+	return 1
+}
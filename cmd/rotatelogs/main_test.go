@@ -0,0 +1,129 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-rotatelogs-cmd")
+	if !assert.NoError(t, err, "creating temporary directory should succeed") {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	status := run([]string{"-path", dir, "-name", "app", "--", "sh", "-c", "echo out; echo err >&2; exit 3"})
+	assert.Equal(t, 3, status, "the exit status of the command should be returned")
+
+	matches, err := filepath.Glob(filepath.Join(dir, "app-*.log"))
+	if !assert.NoError(t, err) || !assert.Len(t, matches, 1) {
+		return
+	}
+	content, err := ioutil.ReadFile(matches[0])
+	if !assert.NoError(t, err, "reading the log file should succeed") {
+		return
+	}
+	assert.Equal(t, "out\nerr\n", string(content))
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-rotatelogs-cmd")
+	if !assert.NoError(t, err, "creating temporary directory should succeed") {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "config.json")
+	data := `{"file_path":"/var/log","file_name":"file","rotation_size":"1MiB","compress":true}`
+	if !assert.NoError(t, ioutil.WriteFile(file, []byte(data), 0644)) {
+		return
+	}
+	os.Setenv("TEST_ROTATELOGS_FILE_NAME", "env")
+	os.Setenv("TEST_ROTATELOGS_ROTATION_SIZE", "2MiB")
+	defer os.Unsetenv("TEST_ROTATELOGS_FILE_NAME")
+	defer os.Unsetenv("TEST_ROTATELOGS_ROTATION_SIZE")
+
+	var f flags
+	fs := newFlagSet(&f)
+	err = fs.Parse([]string{"-config", file, "-env-prefix", "TEST_ROTATELOGS", "-rotation-size", "3MiB", "-post-rotate", "gzip -9"})
+	if !assert.NoError(t, err, "parsing flags should succeed") {
+		return
+	}
+	if !assert.NoError(t, loadConfig(fs, &f), "loadConfig should succeed") {
+		return
+	}
+
+	assert.Equal(t, "/var/log", f.cfg.FilePath, "the file should set the path")
+	assert.True(t, f.cfg.Compress, "the file should set compression")
+	assert.Equal(t, "env", f.cfg.FileName, "the environment should override the file")
+	assert.EqualValues(t, 3<<20, f.cfg.RotationSize, "flags should override the environment")
+	if assert.NotNil(t, f.cfg.PostRotate) {
+		assert.Equal(t, "gzip", f.cfg.PostRotate.Path)
+		assert.Equal(t, []string{"-9"}, f.cfg.PostRotate.Args)
+		assert.True(t, f.cfg.PostRotate.OnRotate)
+	}
+}
+
+func TestPipe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-rotatelogs-cmd")
+	if !assert.NoError(t, err, "creating temporary directory should succeed") {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	rl, err := rotatelogs.NewFromConfig(rotatelogs.Config{FilePath: dir, FileName: "app"})
+	if !assert.NoError(t, err, "rotatelogs.NewFromConfig should succeed") {
+		return
+	}
+	out := &output{rl: rl}
+
+	// the reader stays open: only the signal ends the copy
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	long := strings.Repeat("x", maxLineLength+10)
+	go func() {
+		io.WriteString(pw, "first\n"+long+"\npartial")
+	}()
+	sigs := make(chan os.Signal, 1)
+	done := make(chan int)
+	go func() {
+		done <- pipe(pr, out, sigs)
+	}()
+
+	pending := func() string {
+		out.mutex.Lock()
+		defer out.mutex.Unlock()
+		return string(out.pending)
+	}
+	for deadline := time.Now().Add(5 * time.Second); pending() != "partial" && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !assert.Equal(t, "partial", pending(), "the partial line should be pending") {
+		return
+	}
+
+	sigs <- syscall.SIGTERM
+	assert.Equal(t, 0, <-done, "pipe should return 0 on SIGTERM")
+	if !assert.NoError(t, out.close(), "closing the output should succeed") {
+		return
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "app-*.log"))
+	if !assert.NoError(t, err) || !assert.Len(t, matches, 1) {
+		return
+	}
+	content, err := ioutil.ReadFile(matches[0])
+	if !assert.NoError(t, err, "reading the log file should succeed") {
+		return
+	}
+	assert.Equal(t, "first\n"+long+"\npartial", string(content), "the partial line should be flushed on close")
+}
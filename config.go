@@ -0,0 +1,376 @@
+package rotatelogs
+
+import (
+	"encoding"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chriszhangmq/file-rotatelogs/internal/common"
+	"github.com/chriszhangmq/file-rotatelogs/internal/units"
+	"github.com/pkg/errors"
+	"github.com/robfig/cron"
+)
+
+// Config describes a RotateLogs object in a form that can be loaded from
+// JSON or YAML, and overlaid with environment variables by LoadEnv. The
+// options that take code, such as handlers and hooks, are passed to
+// NewFromConfig next to it.
+type Config struct {
+	FilePath string `json:"file_path" yaml:"file_path"`
+	FileName string `json:"file_name" yaml:"file_name"`
+	// time zone of the dates in file names, e.g. "UTC" or
+	// "Asia/Shanghai" (default: local time)
+	Location string `json:"location,omitempty" yaml:"location,omitempty"`
+
+	MaxAge Duration `json:"max_age,omitempty" yaml:"max_age,omitempty"`
+	// "name" (default) or "mtime", see WithAgeSource
+	AgeSource     string   `json:"age_source,omitempty" yaml:"age_source,omitempty"`
+	RotationTime  Duration `json:"rotation_time,omitempty" yaml:"rotation_time,omitempty"` // whole days
+	RotationSize  Size     `json:"rotation_size,omitempty" yaml:"rotation_size,omitempty"`
+	RotationCount uint     `json:"rotation_count,omitempty" yaml:"rotation_count,omitempty"`
+
+	Compress         bool     `json:"compress,omitempty" yaml:"compress,omitempty"`
+	CronTime         string   `json:"cron_time,omitempty" yaml:"cron_time,omitempty"`
+	TrashGracePeriod Duration `json:"trash_grace_period,omitempty" yaml:"trash_grace_period,omitempty"`
+	// "async" (default) or "sync", see WithEventDelivery
+	EventDelivery string `json:"event_delivery,omitempty" yaml:"event_delivery,omitempty"`
+
+	PostRotate *PostRotateConfig  `json:"post_rotate,omitempty" yaml:"post_rotate,omitempty"`
+	Shipper    *ShipperFileConfig `json:"shipper,omitempty" yaml:"shipper,omitempty"`
+}
+
+// PostRotateConfig is the configuration form of PostRotateCommand.
+type PostRotateConfig struct {
+	Path          string   `json:"path" yaml:"path"`
+	Args          []string `json:"args,omitempty" yaml:"args,omitempty"`
+	Timeout       Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	MaxConcurrent int      `json:"max_concurrent,omitempty" yaml:"max_concurrent,omitempty"`
+	OnRotate      bool     `json:"on_rotate,omitempty" yaml:"on_rotate,omitempty"`
+	OnCompress    bool     `json:"on_compress,omitempty" yaml:"on_compress,omitempty"`
+}
+
+// ShipperFileConfig is the configuration form of ShipperConfig, shipping
+// to a LocalObjectStore in Dir. Other stores are set up in code with
+// WithShipper.
+type ShipperFileConfig struct {
+	Dir               string   `json:"dir" yaml:"dir"`
+	Prefix            string   `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+	MaxAttempts       int      `json:"max_attempts,omitempty" yaml:"max_attempts,omitempty"`
+	MinBackoff        Duration `json:"min_backoff,omitempty" yaml:"min_backoff,omitempty"`
+	MaxBackoff        Duration `json:"max_backoff,omitempty" yaml:"max_backoff,omitempty"`
+	DeleteAfterUpload bool     `json:"delete_after_upload,omitempty" yaml:"delete_after_upload,omitempty"`
+}
+
+// Duration is a time.Duration written as text, e.g. "36h", "90m" or, for
+// whole days, "7d".
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := units.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// String and Set make Duration a flag.Value.
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d *Duration) Set(s string) error {
+	return d.UnmarshalText([]byte(s))
+}
+
+// Size is a number of bytes written as text with an optional unit, e.g.
+// "512MiB" or "100MB".
+type Size int64
+
+func (s Size) MarshalText() ([]byte, error) {
+	return []byte(units.FormatSize(int64(s))), nil
+}
+
+func (s *Size) UnmarshalText(text []byte) error {
+	v, err := units.ParseSize(string(text))
+	if err != nil {
+		return err
+	}
+	*s = Size(v)
+	return nil
+}
+
+// String and Set make Size a flag.Value.
+func (s Size) String() string {
+	return units.FormatSize(int64(s))
+}
+
+func (s *Size) Set(str string) error {
+	return s.UnmarshalText([]byte(str))
+}
+
+// ConfigErrors lists every problem found in a Config.
+type ConfigErrors []error
+
+func (e ConfigErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return "invalid configuration: " + strings.Join(msgs, "; ")
+}
+
+// Validate checks the whole configuration and returns a ConfigErrors
+// holding every problem, or nil.
+func (c *Config) Validate() error {
+	var errs ConfigErrors
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, errors.Errorf(format, args...))
+	}
+
+	if strings.TrimSpace(c.FilePath) == common.IsNull {
+		add("file_path is missing")
+	}
+	if strings.TrimSpace(c.FileName) == common.IsNull {
+		add("file_name is missing")
+	}
+	if c.Location != common.IsNull {
+		if _, err := time.LoadLocation(c.Location); err != nil {
+			add("location: unknown time zone %q", c.Location)
+		}
+	}
+	if c.MaxAge < 0 {
+		add("max_age must not be negative")
+	}
+	if c.MaxAge > 0 && c.RotationCount > 0 {
+		add("max_age and rotation_count cannot be both set")
+	}
+	if _, ok := ageSources[c.AgeSource]; !ok {
+		add("age_source must be \"name\" or \"mtime\", not %q", c.AgeSource)
+	}
+	if c.RotationTime < 0 || time.Duration(c.RotationTime)%(24*time.Hour) != 0 {
+		add("rotation_time must be a whole number of days, not %s", time.Duration(c.RotationTime))
+	}
+	if c.RotationSize < 0 {
+		add("rotation_size must not be negative")
+	}
+	if c.CronTime != common.IsNull {
+		if _, err := cron.Parse(c.CronTime); err != nil {
+			add("cron_time: %s", err)
+		}
+	}
+	if c.TrashGracePeriod < 0 {
+		add("trash_grace_period must not be negative")
+	}
+	if _, ok := eventDeliveries[c.EventDelivery]; !ok {
+		add("event_delivery must be \"async\" or \"sync\", not %q", c.EventDelivery)
+	}
+	if p := c.PostRotate; p != nil {
+		if p.Path == common.IsNull {
+			add("post_rotate.path is missing")
+		}
+		if p.Timeout < 0 {
+			add("post_rotate.timeout must not be negative")
+		}
+		if !p.OnRotate && !p.OnCompress {
+			add("post_rotate needs on_rotate or on_compress")
+		}
+	}
+	if s := c.Shipper; s != nil {
+		if s.Dir == common.IsNull {
+			add("shipper.dir is missing")
+		}
+		if s.MinBackoff < 0 || s.MaxBackoff < 0 {
+			add("shipper backoffs must not be negative")
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+var ageSources = map[string]AgeSource{
+	"":      AgeFromFileName,
+	"name":  AgeFromFileName,
+	"mtime": AgeFromModTime,
+}
+
+var eventDeliveries = map[string]EventDelivery{
+	"":      AsyncDelivery,
+	"async": AsyncDelivery,
+	"sync":  SyncDelivery,
+}
+
+// Options validates the configuration and converts it into the
+// equivalent options, e.g. to pass them to Manager.Add.
+func (c *Config) Options() ([]Option, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	filePath := c.FilePath
+	if !strings.HasSuffix(filePath, "/") && !strings.HasSuffix(filePath, string(os.PathSeparator)) {
+		filePath += "/"
+	}
+	options := []Option{
+		WithFilePath(filePath),
+		WithFileName(c.FileName),
+		WithCompressFile(c.Compress),
+		WithAgeSource(ageSources[c.AgeSource]),
+		WithEventDelivery(eventDeliveries[c.EventDelivery]),
+	}
+	if c.Location != common.IsNull {
+		loc, _ := time.LoadLocation(c.Location)
+		options = append(options, WithLocation(loc))
+	}
+	if c.MaxAge > 0 {
+		options = append(options, WithMaxAgeDuration(time.Duration(c.MaxAge)))
+	}
+	if c.RotationTime > 0 {
+		options = append(options, WithRotationTime(int(time.Duration(c.RotationTime)/(24*time.Hour))))
+	}
+	if c.RotationSize > 0 {
+		options = append(options, WithRotationSizeBytes(int64(c.RotationSize)))
+	}
+	if c.RotationCount > 0 {
+		options = append(options, WithRotationCount(c.RotationCount))
+	}
+	if c.CronTime != common.IsNull {
+		options = append(options, WithCronTime(c.CronTime))
+	}
+	if c.TrashGracePeriod > 0 {
+		options = append(options, WithTrashGracePeriod(time.Duration(c.TrashGracePeriod)))
+	}
+	if p := c.PostRotate; p != nil {
+		options = append(options, WithPostRotateCommand(PostRotateCommand{
+			Path:          p.Path,
+			Args:          p.Args,
+			Timeout:       time.Duration(p.Timeout),
+			MaxConcurrent: p.MaxConcurrent,
+			OnRotate:      p.OnRotate,
+			OnCompress:    p.OnCompress,
+		}))
+	}
+	if s := c.Shipper; s != nil {
+		options = append(options, WithShipper(ShipperConfig{
+			Store:             NewLocalObjectStore(s.Dir),
+			Prefix:            s.Prefix,
+			MaxAttempts:       s.MaxAttempts,
+			MinBackoff:        time.Duration(s.MinBackoff),
+			MaxBackoff:        time.Duration(s.MaxBackoff),
+			DeleteAfterUpload: s.DeleteAfterUpload,
+		}))
+	}
+	return options, nil
+}
+
+// NewFromConfig validates cfg and creates a RotateLogs object from it.
+// The options are applied after those of the configuration, so they can
+// add handlers and hooks or override configured values.
+func NewFromConfig(cfg Config, options ...Option) (*RotateLogs, error) {
+	cfgOptions, err := cfg.Options()
+	if err != nil {
+		return nil, err
+	}
+	return New(append(cfgOptions, options...)...)
+}
+
+// LoadEnv overlays the configuration with environment variables named
+// after the JSON keys, upper-cased and prefixed with prefix and an
+// underscore: with the prefix "APP_LOG", APP_LOG_MAX_AGE=36h sets MaxAge
+// and APP_LOG_POST_ROTATE_PATH sets PostRotate.Path. List values are
+// separated by white space. Every malformed variable is reported in the
+// returned ConfigErrors.
+func (c *Config) LoadEnv(prefix string) error {
+	var errs ConfigErrors
+	loadEnv(reflect.ValueOf(c).Elem(), strings.ToUpper(prefix), &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func loadEnv(v reflect.Value, prefix string, errs *ConfigErrors) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if key == common.IsNull || key == "-" {
+			continue
+		}
+		name := strings.ToUpper(key)
+		if prefix != common.IsNull {
+			name = prefix + "_" + name
+		}
+		field := v.Field(i)
+
+		if field.Kind() == reflect.Ptr && field.Type().Elem().Kind() == reflect.Struct {
+			if field.IsNil() {
+				if !hasEnvPrefix(name + "_") {
+					continue
+				}
+				field.Set(reflect.New(field.Type().Elem()))
+			}
+			loadEnv(field.Elem(), name, errs)
+			continue
+		}
+
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setFromEnv(field, value); err != nil {
+			*errs = append(*errs, errors.Wrapf(err, "%s", name))
+		}
+	}
+}
+
+func hasEnvPrefix(prefix string) bool {
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func setFromEnv(field reflect.Value, value string) error {
+	if u, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(value))
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.Errorf("invalid boolean %q", value)
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return errors.Errorf("invalid integer %q", value)
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return errors.Errorf("invalid unsigned integer %q", value)
+		}
+		field.SetUint(n)
+	case reflect.Slice:
+		field.Set(reflect.ValueOf(strings.Fields(value)))
+	default:
+		return errors.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package rotatelogs_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigValidate(t *testing.T) {
+	valid := rotatelogs.Config{
+		FilePath:     "/var/log",
+		FileName:     "app",
+		Location:     "UTC",
+		MaxAge:       rotatelogs.Duration(7 * 24 * time.Hour),
+		RotationTime: rotatelogs.Duration(24 * time.Hour),
+		CronTime:     "0 0 * * * *",
+		PostRotate:   &rotatelogs.PostRotateConfig{Path: "gzip", OnRotate: true},
+	}
+	assert.NoError(t, valid.Validate(), "a valid configuration should pass")
+
+	invalid := rotatelogs.Config{
+		FileName:      " ",
+		Location:      "Nowhere/Special",
+		MaxAge:        rotatelogs.Duration(time.Hour),
+		RotationCount: 3,
+		AgeSource:     "ctime",
+		RotationTime:  rotatelogs.Duration(36 * time.Hour),
+		CronTime:      "every day",
+		EventDelivery: "later",
+		PostRotate:    &rotatelogs.PostRotateConfig{},
+		Shipper:       &rotatelogs.ShipperFileConfig{MinBackoff: -1},
+	}
+	err := invalid.Validate()
+	errs, ok := err.(rotatelogs.ConfigErrors)
+	if !assert.True(t, ok, "Validate should return ConfigErrors, got %#v", err) {
+		return
+	}
+	for _, want := range []string{
+		"file_path is missing",
+		"file_name is missing",
+		"location",
+		"max_age and rotation_count",
+		"age_source",
+		"rotation_time",
+		"cron_time",
+		"event_delivery",
+		"post_rotate.path is missing",
+		"post_rotate needs on_rotate or on_compress",
+		"shipper.dir is missing",
+		"shipper backoffs",
+	} {
+		assert.Contains(t, err.Error(), want, "every problem should be reported")
+	}
+	assert.Len(t, errs, 12, "each problem should be a separate error")
+}
+
+func TestConfigLoadEnv(t *testing.T) {
+	var cfg rotatelogs.Config
+	data := `{"file_path":"/var/log","file_name":"file","max_age":"7d","compress":true}`
+	if !assert.NoError(t, json.Unmarshal([]byte(data), &cfg), "unmarshaling the configuration should succeed") {
+		return
+	}
+
+	env := map[string]string{
+		"TEST_LOG_FILE_NAME":             "env",
+		"TEST_LOG_MAX_AGE":               "36h",
+		"TEST_LOG_ROTATION_SIZE":         "2MiB",
+		"TEST_LOG_POST_ROTATE_PATH":      "gzip",
+		"TEST_LOG_POST_ROTATE_ARGS":      "-9  -f",
+		"TEST_LOG_POST_ROTATE_ON_ROTATE": "true",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+	if !assert.NoError(t, cfg.LoadEnv("test_log"), "LoadEnv should succeed") {
+		return
+	}
+
+	assert.Equal(t, "/var/log", cfg.FilePath, "unset variables should keep the loaded value")
+	assert.True(t, cfg.Compress, "unset variables should keep the loaded value")
+	assert.Equal(t, "env", cfg.FileName, "the environment should override the loaded value")
+	assert.Equal(t, rotatelogs.Duration(36*time.Hour), cfg.MaxAge, "the environment should override the loaded value")
+	assert.Equal(t, rotatelogs.Size(2<<20), cfg.RotationSize)
+	if assert.NotNil(t, cfg.PostRotate, "nested variables should create the section") {
+		assert.Equal(t, "gzip", cfg.PostRotate.Path)
+		assert.Equal(t, []string{"-9", "-f"}, cfg.PostRotate.Args, "lists should be split on white space")
+		assert.True(t, cfg.PostRotate.OnRotate)
+	}
+	assert.Nil(t, cfg.Shipper, "sections without variables should stay unset")
+
+	os.Setenv("TEST_LOG_COMPRESS", "maybe")
+	os.Setenv("TEST_LOG_ROTATION_COUNT", "-1")
+	defer os.Unsetenv("TEST_LOG_COMPRESS")
+	defer os.Unsetenv("TEST_LOG_ROTATION_COUNT")
+	err := cfg.LoadEnv("TEST_LOG")
+	if errs, ok := err.(rotatelogs.ConfigErrors); assert.True(t, ok, "LoadEnv should return ConfigErrors, got %#v", err) {
+		assert.Len(t, errs, 2, "every malformed variable should be reported")
+		assert.Contains(t, err.Error(), "TEST_LOG_COMPRESS")
+		assert.Contains(t, err.Error(), "TEST_LOG_ROTATION_COUNT")
+	}
+}
+
+func TestNewFromConfig(t *testing.T) {
+	t.Run("Invalid", func(t *testing.T) {
+		rl, err := rotatelogs.NewFromConfig(rotatelogs.Config{FileName: "app"})
+		assert.Nil(t, rl)
+		_, ok := err.(rotatelogs.ConfigErrors)
+		assert.True(t, ok, "the validation errors should be returned, got %#v", err)
+	})
+
+	t.Run("OptionsOverride", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+
+		// no trailing separator: NewFromConfig adds it
+		cfg := rotatelogs.Config{FilePath: dir, FileName: "configured", EventDelivery: "sync"}
+		var created []string
+		rl, err := rotatelogs.NewFromConfig(cfg,
+			rotatelogs.WithFileName("app"),
+			rotatelogs.WithHandler(rotatelogs.HandlerFunc(func(e rotatelogs.Event) {
+				if ev, ok := e.(*rotatelogs.FileCreatedEvent); ok {
+					created = append(created, ev.File())
+				}
+			})))
+		if !assert.NoError(t, err, "NewFromConfig should succeed") {
+			return
+		}
+		defer rl.Close()
+		_, err = rl.Write([]byte("hello\n"))
+		if !assert.NoError(t, err, "Write should succeed") {
+			return
+		}
+
+		if assert.Len(t, created, 1, "the configured sync delivery should report the file before Write returns") {
+			assert.Equal(t, dir, filepath.Dir(created[0]), "the file should be in the configured directory")
+			assert.True(t, strings.HasPrefix(filepath.Base(created[0]), "app-"), "the option should override the configured name, got %s", created[0])
+		}
+	})
+}
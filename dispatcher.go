@@ -0,0 +1,257 @@
+package rotatelogs
+
+import "sync"
+
+// EventDelivery selects how events are handed to the Handler
+type EventDelivery int
+
+const (
+	// AsyncDelivery queues events and delivers them in order from a
+	// dedicated goroutine, so writers never wait for the Handler. When
+	// maxQueuedEvents are waiting for a slow Handler, further events are
+	// dropped and counted by DroppedEvents.
+	AsyncDelivery EventDelivery = iota
+	// SyncDelivery invokes the Handler from the goroutine that produced
+	// the event. The Handler may be called while the RotateLogs object
+	// holds its lock, so it must not call back into the object.
+	SyncDelivery
+)
+
+// maxQueuedEvents bounds the events waiting for the Handler in
+// AsyncDelivery mode
+const maxQueuedEvents = 1024
+
+// dispatcher delivers the events of a single RotateLogs object in the
+// order they were produced, shielding the object from panicking handlers.
+type dispatcher struct {
+	handler  Handler
+	delivery EventDelivery
+	diag     *diagOutput
+
+	mutex   sync.Mutex
+	queue   []Event
+	wake    chan struct{}
+	done    chan struct{}
+	started bool
+	closed  bool
+
+	// serialises synchronous deliveries coming from different goroutines
+	syncMutex sync.Mutex
+
+	subsMutex  sync.Mutex
+	subs       []*subscriber
+	subsClosed bool
+	dropped    uint64
+}
+
+// subscriber is a channel registered through Subscribe
+type subscriber struct {
+	ch chan Event
+}
+
+func newDispatcher(handler Handler, delivery EventDelivery, diag *diagOutput) *dispatcher {
+	return &dispatcher{
+		diag:     diag,
+		handler:  handler,
+		delivery: delivery,
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+}
+
+func (d *dispatcher) dispatch(e Event) {
+	if d.handler == nil && !d.hasSubscribers() {
+		return
+	}
+
+	if d.delivery == SyncDelivery {
+		d.syncMutex.Lock()
+		defer d.syncMutex.Unlock()
+		if !d.isClosed() {
+			d.deliver(e)
+		}
+		return
+	}
+
+	d.mutex.Lock()
+	if d.closed {
+		d.mutex.Unlock()
+		return
+	}
+	if len(d.queue) >= maxQueuedEvents {
+		d.mutex.Unlock()
+		d.drop()
+		return
+	}
+	d.queue = append(d.queue, e)
+	if !d.started {
+		d.started = true
+		go d.loop()
+	}
+	d.mutex.Unlock()
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *dispatcher) isClosed() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.closed
+}
+
+func (d *dispatcher) drop() {
+	d.subsMutex.Lock()
+	defer d.subsMutex.Unlock()
+	d.dropped++
+}
+
+func (d *dispatcher) loop() {
+	defer close(d.done)
+
+	for {
+		d.mutex.Lock()
+		pending := d.queue
+		d.queue = nil
+		closed := d.closed
+		d.mutex.Unlock()
+
+		for _, e := range pending {
+			d.deliver(e)
+		}
+		if len(pending) > 0 {
+			continue
+		}
+		if closed {
+			return
+		}
+		<-d.wake
+	}
+}
+
+func (d *dispatcher) deliver(e Event) {
+	d.publish(e)
+	if d.handler == nil {
+		return
+	}
+
+	defer func() {
+		if v := recover(); v != nil {
+			d.diag.printf("rotatelogs: event handler panicked: %v\n", v)
+		}
+	}()
+	d.handler.Handle(e)
+}
+
+// publish hands the event to every subscriber. A subscriber whose
+// channel is full misses the event rather than blocking the others.
+func (d *dispatcher) publish(e Event) {
+	d.subsMutex.Lock()
+	defer d.subsMutex.Unlock()
+
+	for _, sub := range d.subs {
+		select {
+		case sub.ch <- e:
+		default:
+			d.dropped++
+		}
+	}
+}
+
+func (d *dispatcher) hasSubscribers() bool {
+	d.subsMutex.Lock()
+	defer d.subsMutex.Unlock()
+	return len(d.subs) > 0
+}
+
+func (d *dispatcher) subscribe(buffer int) (<-chan Event, func()) {
+	if buffer < 0 {
+		buffer = 0
+	}
+	sub := &subscriber{ch: make(chan Event, buffer)}
+
+	d.subsMutex.Lock()
+	defer d.subsMutex.Unlock()
+
+	if d.subsClosed {
+		close(sub.ch)
+		return sub.ch, func() {}
+	}
+	d.subs = append(d.subs, sub)
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() { d.unsubscribe(sub) })
+	}
+}
+
+func (d *dispatcher) unsubscribe(sub *subscriber) {
+	d.subsMutex.Lock()
+	defer d.subsMutex.Unlock()
+
+	for i, s := range d.subs {
+		if s == sub {
+			d.subs = append(d.subs[:i], d.subs[i+1:]...)
+			close(sub.ch)
+			return
+		}
+	}
+}
+
+func (d *dispatcher) droppedCount() uint64 {
+	d.subsMutex.Lock()
+	defer d.subsMutex.Unlock()
+	return d.dropped
+}
+
+// closeSubscribers closes every subscriber channel
+func (d *dispatcher) closeSubscribers() {
+	d.subsMutex.Lock()
+	defer d.subsMutex.Unlock()
+
+	for _, sub := range d.subs {
+		close(sub.ch)
+	}
+	d.subs = nil
+	d.subsClosed = true
+}
+
+// close stops accepting events, waits until the pending ones have been
+// delivered and closes the subscriber channels
+func (d *dispatcher) close() {
+	d.mutex.Lock()
+	if d.closed {
+		d.mutex.Unlock()
+		return
+	}
+	d.closed = true
+	started := d.started
+	d.mutex.Unlock()
+
+	if started {
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+		<-d.done
+	}
+	d.closeSubscribers()
+}
+
+// Subscribe registers a channel that receives every event produced by the
+// RotateLogs object, independently of the Handler and of other
+// subscribers. Events are never allowed to block the object: when the
+// channel's buffer is full the event is dropped for that subscriber and
+// counted by DroppedEvents. The channel is closed by cancel or by Close.
+func (rl *RotateLogs) Subscribe(buffer int) (<-chan Event, func()) {
+	return rl.events.subscribe(buffer)
+}
+
+// DroppedEvents returns the number of events that could not be delivered
+// to a subscriber because its channel was full, or to the Handler because
+// too many events were already waiting for it.
+func (rl *RotateLogs) DroppedEvents() uint64 {
+	return rl.events.droppedCount()
+}
@@ -0,0 +1,170 @@
+package rotatelogs_test
+
+import (
+	"os"
+	"sync"
+	"testing"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+// eventRecorder is a Handler that records the types of the events it
+// receives.
+type eventRecorder struct {
+	mutex sync.Mutex
+	types []rotatelogs.EventType
+}
+
+func (r *eventRecorder) Handle(e rotatelogs.Event) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.types = append(r.types, e.Type())
+}
+
+func (r *eventRecorder) received() []rotatelogs.EventType {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]rotatelogs.EventType(nil), r.types...)
+}
+
+func TestEventDelivery(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	expected := []rotatelogs.EventType{
+		rotatelogs.FileCreatedEventType,
+		rotatelogs.FileRotatedEventType,
+		rotatelogs.FileCreatedEventType,
+		rotatelogs.FileRotatedEventType,
+	}
+
+	t.Run("Synchronous delivery", func(t *testing.T) {
+		var rec eventRecorder
+		rl := newLogger(t, dir,
+			rotatelogs.WithClock(clockwork.NewFakeClockAt(testTime)),
+			rotatelogs.WithHandler(&rec),
+			rotatelogs.WithEventDelivery(rotatelogs.SyncDelivery),
+		)
+		defer rl.Close()
+
+		rl.Write([]byte("Hello, World\n"))
+		assert.Equal(t, expected[:2], rec.received(), "events should be delivered before Write returns")
+		rl.Rotate()
+		assert.Equal(t, expected, rec.received(), "events should be delivered before Rotate returns")
+	})
+
+	t.Run("Asynchronous delivery", func(t *testing.T) {
+		var rec eventRecorder
+		rl := newLogger(t, dir,
+			rotatelogs.WithClock(clockwork.NewFakeClockAt(testTime.AddDate(0, 0, 1))),
+			rotatelogs.WithHandler(&rec),
+			rotatelogs.WithEventDelivery(rotatelogs.AsyncDelivery),
+		)
+		rl.Write([]byte("Hello, World\n"))
+		rl.Rotate()
+		assert.NoError(t, rl.Close())
+		assert.Equal(t, expected, rec.received(), "Close should wait for pending events, delivered in order")
+	})
+
+	t.Run("Panicking handlers are recovered", func(t *testing.T) {
+		for _, delivery := range []rotatelogs.EventDelivery{rotatelogs.SyncDelivery, rotatelogs.AsyncDelivery} {
+			var rec eventRecorder
+			handler := rotatelogs.HandlerFunc(func(e rotatelogs.Event) {
+				rec.Handle(e)
+				if e.Type() == rotatelogs.FileCreatedEventType {
+					panic("handler failure")
+				}
+			})
+			rl := newLogger(t, dir,
+				rotatelogs.WithClock(clockwork.NewFakeClockAt(testTime.AddDate(0, 0, 2+int(delivery)))),
+				rotatelogs.WithHandler(handler),
+				rotatelogs.WithEventDelivery(delivery),
+			)
+			n, err := rl.Write([]byte("Hello, World\n"))
+			assert.NoError(t, err, "a panicking handler should not fail the write")
+			assert.Equal(t, 13, n)
+			rl.Rotate()
+			assert.NoError(t, rl.Close())
+			assert.Equal(t, expected, rec.received(), "later events should still be delivered")
+		}
+	})
+
+	t.Run("No delivery after Close", func(t *testing.T) {
+		for _, delivery := range []rotatelogs.EventDelivery{rotatelogs.SyncDelivery, rotatelogs.AsyncDelivery} {
+			var rec eventRecorder
+			rl := newLogger(t, dir,
+				rotatelogs.WithClock(clockwork.NewFakeClockAt(testTime.AddDate(0, 0, 4+int(delivery)))),
+				rotatelogs.WithHandler(&rec),
+				rotatelogs.WithEventDelivery(delivery),
+			)
+			rl.Write([]byte("Hello, World\n"))
+			assert.NoError(t, rl.Close())
+			// maintenance still runs on a closed object and reports it
+			rl.RunMaintenance()
+			assert.Equal(t, expected[:2], rec.received(), "events produced after Close should not reach the handler")
+		}
+	})
+
+	t.Run("Bounded queue", func(t *testing.T) {
+		release := make(chan struct{})
+		var rec eventRecorder
+		rl := newLogger(t, dir,
+			rotatelogs.WithClock(clockwork.NewFakeClockAt(testTime.AddDate(0, 0, 6))),
+			rotatelogs.WithHandler(rotatelogs.HandlerFunc(func(e rotatelogs.Event) {
+				<-release
+				rec.Handle(e)
+			})),
+			rotatelogs.WithEventDelivery(rotatelogs.AsyncDelivery),
+		)
+		// the handler is stuck on the first event while the others
+		// pile up
+		const runs = 2000
+		for i := 0; i < runs; i++ {
+			rl.RunMaintenance()
+		}
+		close(release)
+		assert.NoError(t, rl.Close())
+
+		delivered := len(rec.received())
+		assert.True(t, delivered < runs, "the queue should be bounded, %d events were delivered", delivered)
+		assert.EqualValues(t, runs-delivered, rl.DroppedEvents(), "every event that did not fit should be counted")
+	})
+}
+
+func TestSubscribe(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	rl := newLogger(t, dir, rotatelogs.WithClock(clockwork.NewFakeClockAt(testTime)))
+
+	all, cancelAll := rl.Subscribe(16)
+	defer cancelAll()
+	small, cancelSmall := rl.Subscribe(1)
+	defer cancelSmall()
+	gone, cancelGone := rl.Subscribe(16)
+	cancelGone()
+	cancelGone() // cancelling twice is harmless
+
+	_, ok := <-gone
+	assert.False(t, ok, "cancel should close the channel")
+
+	rl.Write([]byte("Hello, World\n"))
+
+	assert.Equal(t, rotatelogs.FileCreatedEventType, (<-all).Type())
+	assert.Equal(t, rotatelogs.FileRotatedEventType, (<-all).Type())
+	assert.Equal(t, rotatelogs.FileCreatedEventType, (<-small).Type())
+	assert.EqualValues(t, 1, rl.DroppedEvents(), "the event that did not fit should be counted")
+
+	assert.NoError(t, rl.Close())
+	_, ok = <-all
+	assert.False(t, ok, "Close should close the channels")
+	_, ok = <-small
+	assert.False(t, ok, "Close should close the channels")
+
+	late, cancelLate := rl.Subscribe(1)
+	defer cancelLate()
+	_, ok = <-late
+	assert.False(t, ok, "subscribing after Close should return a closed channel")
+}
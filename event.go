@@ -1,5 +1,7 @@
 package rotatelogs
 
+import "time"
+
 func (h HandlerFunc) Handle(e Event) {
 	h(e)
 }
@@ -15,3 +17,315 @@ func (e *FileRotatedEvent) PreviousFile() string {
 func (e *FileRotatedEvent) CurrentFile() string {
 	return e.current
 }
+
+func (e *FileRotatedEvent) Time() time.Time {
+	return e.time
+}
+
+func (e *FileRotatedEvent) Reason() RotationReason {
+	return e.reason
+}
+
+// PreviousSize returns the final size of the previous file. When the
+// file has disappeared it is the number of bytes written to it.
+func (e *FileRotatedEvent) PreviousSize() int64 {
+	return e.prevSize
+}
+
+// PreviousWrites returns the number of writes made to the previous file.
+func (e *FileRotatedEvent) PreviousWrites() uint64 {
+	return e.prevWrites
+}
+
+// Start returns when the previous file was opened.
+func (e *FileRotatedEvent) Start() time.Time {
+	return e.start
+}
+
+// End returns the time of the last write to the previous file, or the
+// time it was opened if it was never written to.
+func (e *FileRotatedEvent) End() time.Time {
+	return e.end
+}
+
+// MarshalText lets reasons be used as readable JSON object keys.
+func (r RotationReason) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r RotationReason) String() string {
+	switch r {
+	case RotationReasonStartup:
+		return "startup"
+	case RotationReasonTime:
+		return "time"
+	case RotationReasonSize:
+		return "size"
+	case RotationReasonManual:
+		return "manual"
+	case RotationReasonFileMissing:
+		return "file_missing"
+	default:
+		return "unknown"
+	}
+}
+
+func (e *FileCreatedEvent) Type() EventType {
+	return FileCreatedEventType
+}
+
+func (e *FileCreatedEvent) File() string {
+	return e.file
+}
+
+func (e *FileCreatedEvent) Time() time.Time {
+	return e.time
+}
+
+func (e *FileCompressedEvent) Type() EventType {
+	return FileCompressedEventType
+}
+
+func (e *FileCompressedEvent) SourceFile() string {
+	return e.source
+}
+
+func (e *FileCompressedEvent) File() string {
+	return e.file
+}
+
+func (e *FileCompressedEvent) OriginalSize() int64 {
+	return e.originalSize
+}
+
+func (e *FileCompressedEvent) CompressedSize() int64 {
+	return e.compressedSize
+}
+
+func (e *FileCompressedEvent) Duration() time.Duration {
+	return e.duration
+}
+
+func (e *FileCompressedEvent) Time() time.Time {
+	return e.time
+}
+
+func (e *FileDeletedEvent) Type() EventType {
+	return FileDeletedEventType
+}
+
+func (e *FileDeletedEvent) File() string {
+	return e.file
+}
+
+func (e *FileDeletedEvent) Size() int64 {
+	return e.size
+}
+
+// Quarantined reports whether the file was moved into the trash
+// directory rather than removed.
+func (e *FileDeletedEvent) Quarantined() bool {
+	return e.quarantined
+}
+
+func (e *FileDeletedEvent) Time() time.Time {
+	return e.time
+}
+
+func (e *RotationFailedEvent) Type() EventType {
+	return RotationFailedEventType
+}
+
+func (e *RotationFailedEvent) File() string {
+	return e.file
+}
+
+func (e *RotationFailedEvent) Err() error {
+	return e.err
+}
+
+func (e *RotationFailedEvent) Time() time.Time {
+	return e.time
+}
+
+func (e *CompressionFailedEvent) Type() EventType {
+	return CompressionFailedEventType
+}
+
+func (e *CompressionFailedEvent) File() string {
+	return e.file
+}
+
+func (e *CompressionFailedEvent) Err() error {
+	return e.err
+}
+
+func (e *CompressionFailedEvent) Time() time.Time {
+	return e.time
+}
+
+func (e *MaintenanceCompletedEvent) Type() EventType {
+	return MaintenanceCompletedEventType
+}
+
+func (e *MaintenanceCompletedEvent) Time() time.Time {
+	return e.start
+}
+
+func (e *MaintenanceCompletedEvent) Duration() time.Duration {
+	return e.duration
+}
+
+func (e *MaintenanceCompletedEvent) Compressed() int {
+	return e.compressed
+}
+
+func (e *MaintenanceCompletedEvent) Deleted() int {
+	return e.deleted
+}
+
+// Err returns the first error encountered during the run, if any.
+func (e *MaintenanceCompletedEvent) Err() error {
+	return e.err
+}
+
+func (e *CommandCompletedEvent) Type() EventType {
+	return CommandCompletedEventType
+}
+
+func (e *CommandCompletedEvent) Command() string {
+	return e.command
+}
+
+func (e *CommandCompletedEvent) File() string {
+	return e.file
+}
+
+// Trigger returns the type of the event the command was run for,
+// FileRotatedEventType or FileCompressedEventType.
+func (e *CommandCompletedEvent) Trigger() EventType {
+	return e.trigger
+}
+
+// Stdout returns the standard output of the command, truncated to its
+// last 64 KiB.
+func (e *CommandCompletedEvent) Stdout() []byte {
+	return e.stdout
+}
+
+// Stderr returns the standard error of the command, truncated to its
+// last 64 KiB.
+func (e *CommandCompletedEvent) Stderr() []byte {
+	return e.stderr
+}
+
+// ExitCode returns the exit status of the command, or -1 if it could not
+// be started or was killed.
+func (e *CommandCompletedEvent) ExitCode() int {
+	return e.exitCode
+}
+
+func (e *CommandCompletedEvent) Err() error {
+	return e.err
+}
+
+func (e *CommandCompletedEvent) Duration() time.Duration {
+	return e.duration
+}
+
+func (e *CommandCompletedEvent) Time() time.Time {
+	return e.time
+}
+
+func (e *FileShippedEvent) Type() EventType {
+	return FileShippedEventType
+}
+
+func (e *FileShippedEvent) File() string {
+	return e.file
+}
+
+func (e *FileShippedEvent) Key() string {
+	return e.key
+}
+
+func (e *FileShippedEvent) Attempts() int {
+	return e.attempts
+}
+
+// Deleted reports whether the local file was removed after the upload.
+func (e *FileShippedEvent) Deleted() bool {
+	return e.deleted
+}
+
+func (e *FileShippedEvent) Time() time.Time {
+	return e.time
+}
+
+func (e *ShippingFailedEvent) Type() EventType {
+	return ShippingFailedEventType
+}
+
+func (e *ShippingFailedEvent) File() string {
+	return e.file
+}
+
+func (e *ShippingFailedEvent) Key() string {
+	return e.key
+}
+
+func (e *ShippingFailedEvent) Attempts() int {
+	return e.attempts
+}
+
+// GaveUp reports whether the shipper stopped retrying the upload.
+func (e *ShippingFailedEvent) GaveUp() bool {
+	return e.gaveUp
+}
+
+func (e *ShippingFailedEvent) Err() error {
+	return e.err
+}
+
+func (e *ShippingFailedEvent) Time() time.Time {
+	return e.time
+}
+
+func (e *FileReopenedEvent) Type() EventType {
+	return FileReopenedEventType
+}
+
+func (e *FileReopenedEvent) File() string {
+	return e.file
+}
+
+func (e *FileReopenedEvent) Reason() ReopenReason {
+	return e.reason
+}
+
+// Size is the size of the file found under the current name when it was
+// reopened, 0 if it had to be created.
+func (e *FileReopenedEvent) Size() int64 {
+	return e.size
+}
+
+func (e *FileReopenedEvent) Time() time.Time {
+	return e.time
+}
+
+func (r ReopenReason) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r ReopenReason) String() string {
+	switch r {
+	case ReopenReasonManual:
+		return "manual"
+	case ReopenReasonReplaced:
+		return "replaced"
+	case ReopenReasonTruncated:
+		return "truncated"
+	default:
+		return "unknown"
+	}
+}
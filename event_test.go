@@ -0,0 +1,129 @@
+package rotatelogs_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+// rotations subscribes to the FileRotatedEvents of rl and returns a
+// function that collects the ones produced so far.
+func rotations(rl *rotatelogs.RotateLogs) func() []*rotatelogs.FileRotatedEvent {
+	ch, _ := rl.Subscribe(64)
+	return func() []*rotatelogs.FileRotatedEvent {
+		var events []*rotatelogs.FileRotatedEvent
+		for {
+			select {
+			case e := <-ch:
+				if ev, ok := e.(*rotatelogs.FileRotatedEvent); ok {
+					events = append(events, ev)
+				}
+			default:
+				return events
+			}
+		}
+	}
+}
+
+func TestFileRotatedEvent(t *testing.T) {
+	line := []byte("0123456789\n")
+
+	t.Run("Startup and size", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		clock := clockwork.NewFakeClockAt(testTime)
+		rl := newLogger(t, dir, rotatelogs.WithClock(clock), rotatelogs.WithRotationSizeBytes(20))
+		defer rl.Close()
+		collect := rotations(rl)
+
+		rl.Write(line)
+		clock.Advance(time.Minute)
+		rl.Write(line)
+		first := rl.CurrentFileName()
+		clock.Advance(time.Minute)
+		rl.Write(line)
+
+		events := collect()
+		if !assert.Len(t, events, 2) {
+			return
+		}
+		startup := events[0]
+		assert.Equal(t, rotatelogs.RotationReasonStartup, startup.Reason())
+		assert.Equal(t, "", startup.PreviousFile())
+		assert.Equal(t, first, startup.CurrentFile())
+		assert.True(t, startup.Time().Equal(testTime))
+
+		size := events[1]
+		assert.Equal(t, rotatelogs.RotationReasonSize, size.Reason())
+		assert.Equal(t, first, size.PreviousFile())
+		assert.Equal(t, rl.CurrentFileName(), size.CurrentFile())
+		assert.NotEqual(t, first, size.CurrentFile())
+		assert.EqualValues(t, 2*len(line), size.PreviousSize())
+		assert.EqualValues(t, 2, size.PreviousWrites())
+		assert.True(t, size.Start().Equal(testTime), "the range should start when the file was opened")
+		assert.True(t, size.End().Equal(testTime.Add(time.Minute)), "the range should end at the last write")
+		assert.True(t, size.Time().Equal(testTime.Add(2*time.Minute)))
+	})
+
+	t.Run("Time", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		clock := clockwork.NewFakeClockAt(testTime)
+		rl := newLogger(t, dir, rotatelogs.WithClock(clock), rotatelogs.WithRotationTime(1))
+		defer rl.Close()
+		collect := rotations(rl)
+
+		rl.Write(line)
+		clock.Advance(48 * time.Hour)
+		rl.Write(line)
+
+		events := collect()
+		if !assert.Len(t, events, 2) {
+			return
+		}
+		assert.Equal(t, rotatelogs.RotationReasonTime, events[1].Reason())
+		assert.EqualValues(t, len(line), events[1].PreviousSize())
+		assert.EqualValues(t, 1, events[1].PreviousWrites())
+	})
+
+	t.Run("Manual and missing file", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		clock := clockwork.NewFakeClockAt(testTime)
+		rl := newLogger(t, dir, rotatelogs.WithClock(clock))
+		defer rl.Close()
+		collect := rotations(rl)
+
+		if !assert.NoError(t, rl.Rotate(), "the first rotation should succeed") {
+			return
+		}
+		clock.Advance(time.Minute)
+		if !assert.NoError(t, rl.Rotate(), "rl.Rotate should succeed") {
+			return
+		}
+		rl.Write(line)
+		rl.Write(line)
+		if !assert.NoError(t, os.Remove(rl.CurrentFileName())) {
+			return
+		}
+		rl.Write(line)
+
+		events := collect()
+		if !assert.Len(t, events, 3) {
+			return
+		}
+		assert.Equal(t, rotatelogs.RotationReasonStartup, events[0].Reason())
+		assert.Equal(t, rotatelogs.RotationReasonManual, events[1].Reason())
+		assert.EqualValues(t, 0, events[1].PreviousWrites())
+		assert.True(t, events[1].End().Equal(events[1].Start()), "an unwritten file should have an empty range")
+
+		missing := events[2]
+		assert.Equal(t, rotatelogs.RotationReasonFileMissing, missing.Reason())
+		assert.EqualValues(t, 2*len(line), missing.PreviousSize(), "the size of a missing file is the number of bytes written")
+		assert.EqualValues(t, 2, missing.PreviousWrites())
+	})
+}
@@ -0,0 +1,67 @@
+package rotatelogs
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chriszhangmq/file-rotatelogs/internal/common"
+)
+
+// LogFile describes one of the files of a RotateLogs object.
+type LogFile struct {
+	Path       string    `json:"path"`
+	Date       time.Time `json:"date"` // from the file name, zero if it is not a valid date
+	Part       int       `json:"part"` // 0 for the first file of a day
+	Size       int64     `json:"size"`
+	ModTime    time.Time `json:"mod_time"`
+	Compressed bool      `json:"compressed"`
+	Pinned     bool      `json:"pinned"`
+	Current    bool      `json:"current"`
+}
+
+// Files returns the log files of the object, compressed or not, in the
+// order they were written: by date, then by part. Lock files and
+// symlinks are left out.
+func (rl *RotateLogs) Files() ([]LogFile, error) {
+	matches, err := rl.matcher.Glob()
+	if err != nil {
+		return nil, err
+	}
+	curFn := rl.CurrentFileName()
+	loc := rl.clock.Now().Location()
+
+	files := make([]LogFile, 0, len(matches))
+	for _, path := range matches {
+		if strings.HasSuffix(path, common.LockSuffix) || strings.HasSuffix(path, common.SymlinkSuffix) {
+			continue
+		}
+		fi, err := os.Lstat(path)
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		date, _ := rl.matcher.ParseTime(path, loc)
+		files = append(files, LogFile{
+			Path:       path,
+			Date:       date,
+			Part:       rl.matcher.Part(path),
+			Size:       fi.Size(),
+			ModTime:    fi.ModTime(),
+			Compressed: strings.HasSuffix(path, common.CompressSuffix),
+			Pinned:     rl.pins.has(path),
+			Current:    path == curFn,
+		})
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		a, b := files[i], files[j]
+		if !a.Date.Equal(b.Date) {
+			return a.Date.Before(b.Date)
+		}
+		if a.Part != b.Part {
+			return a.Part < b.Part
+		}
+		return !a.Compressed && b.Compressed
+	})
+	return files, nil
+}
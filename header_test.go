@@ -0,0 +1,110 @@
+package rotatelogs_test
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderFooter(t *testing.T) {
+	// footerOptions returns the options of a logger writing a header and a
+	// footer, and records the summaries passed to the footer in summaries
+	footerOptions := func(clock rotatelogs.Clock, summaries *[]rotatelogs.FileSummary) []rotatelogs.Option {
+		return []rotatelogs.Option{
+			rotatelogs.WithClock(clock),
+			rotatelogs.WithHeader(func(w io.Writer, filename string) error {
+				_, err := fmt.Fprintf(w, "# %s\n", filepath.Base(filename))
+				return err
+			}),
+			rotatelogs.WithFooter(func(w io.Writer, s rotatelogs.FileSummary) error {
+				*summaries = append(*summaries, s)
+				_, err := fmt.Fprintf(w, "# %d bytes in %d writes\n", s.Bytes, s.Writes)
+				return err
+			}),
+		}
+	}
+
+	t.Run("Close writes the footer", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+
+		clock := clockwork.NewFakeClockAt(testTime)
+		var summaries []rotatelogs.FileSummary
+		rl := newLogger(t, dir, footerOptions(clock, &summaries)...)
+		rl.Write([]byte("one\n"))
+		if !assert.NoError(t, rl.Close()) {
+			return
+		}
+
+		first := filepath.Join(dir, "app-2021-11-14.log")
+		content, err := ioutil.ReadFile(first)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "# app-2021-11-14.log\none\n# 25 bytes in 1 writes\n", string(content),
+			"Close should write the footer of the last file")
+		if assert.Len(t, summaries, 1) {
+			assert.Equal(t, first, summaries[0].Filename)
+		}
+	})
+
+	t.Run("Footer only on rotation", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+
+		clock := clockwork.NewFakeClockAt(testTime)
+		var summaries []rotatelogs.FileSummary
+		options := append(footerOptions(clock, &summaries), rotatelogs.WithFooterOnClose(false))
+		first := filepath.Join(dir, "app-2021-11-14.log")
+
+		// first run: the file is created with its header and closed
+		// without a footer
+		rl := newLogger(t, dir, options...)
+		rl.Write([]byte("one\n"))
+		if !assert.NoError(t, rl.Close()) {
+			return
+		}
+		content, err := ioutil.ReadFile(first)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "# app-2021-11-14.log\none\n", string(content), "Close should not write the footer")
+
+		// second run: appends without a header, then rotates with a footer
+		rl = newLogger(t, dir, options...)
+		defer rl.Close()
+		clock.Advance(time.Minute)
+		rl.Write([]byte("two\n"))
+		clock.Advance(time.Minute)
+		rl.Write([]byte("three\n"))
+		if !assert.NoError(t, rl.Rotate()) {
+			return
+		}
+
+		content, err = ioutil.ReadFile(first)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "# app-2021-11-14.log\none\ntwo\nthree\n# 10 bytes in 2 writes\n", string(content),
+			"the footer should only be written at the end of the file")
+		if assert.Len(t, summaries, 1) {
+			s := summaries[0]
+			assert.Equal(t, first, s.Filename)
+			assert.True(t, s.Start.Equal(testTime.Add(time.Minute)), "the summary should start when this object opened the file")
+			assert.True(t, s.End.Equal(testTime.Add(2*time.Minute)), "the summary should end at the last write")
+		}
+
+		content, err = ioutil.ReadFile(rl.CurrentFileName())
+		if assert.NoError(t, err) {
+			assert.Equal(t, "# app-2021-11-14.log.1.log\n", string(content), "the new file should start with a header")
+		}
+	})
+}
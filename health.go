@@ -0,0 +1,94 @@
+package rotatelogs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Health reports whether a RotateLogs object is able to do its job.
+type Health struct {
+	Healthy bool `json:"healthy"`
+
+	Directory   string `json:"directory"`
+	DirWritable bool   `json:"dir_writable"`
+	DirError    string `json:"dir_error,omitempty"`
+
+	// FreeBytes is the space available to the process on the file system
+	// holding the logs. It is only meaningful if FreeBytesKnown is set.
+	FreeBytes      uint64 `json:"free_bytes"`
+	FreeBytesKnown bool   `json:"free_bytes_known"`
+
+	LastWriteOK          bool   `json:"last_write_ok"`
+	LastWriteError       string `json:"last_write_error,omitempty"`
+	LastRotationOK       bool   `json:"last_rotation_ok"`
+	LastRotationError    string `json:"last_rotation_error,omitempty"`
+	LastMaintenanceOK    bool   `json:"last_maintenance_ok"`
+	LastMaintenanceError string `json:"last_maintenance_error,omitempty"`
+
+	// LastMaintenance is when the latest successful maintenance run
+	// finished, and MaintenanceAge how long ago that was. Both are zero
+	// if no run has succeeded yet.
+	LastMaintenance time.Time     `json:"last_maintenance"`
+	MaintenanceAge  time.Duration `json:"maintenance_age"`
+}
+
+// Health checks the log directory and reports the outcome of the latest
+// write, rotation and maintenance run. Operations that have not happened
+// yet count as successful.
+func (rl *RotateLogs) Health() Health {
+	h := Health{Directory: rl.matcher.Dir()}
+
+	if err := checkWritable(h.Directory); err != nil {
+		h.DirError = err.Error()
+	} else {
+		h.DirWritable = true
+	}
+	h.FreeBytes, h.FreeBytesKnown = freeBytes(h.Directory)
+
+	c := &rl.stats
+	c.mutex.Lock()
+	h.LastWriteOK, h.LastWriteError = outcome(c.lastWriteErr)
+	h.LastRotationOK, h.LastRotationError = outcome(c.lastRotationErr)
+	h.LastMaintenanceOK, h.LastMaintenanceError = outcome(c.lastMaintenanceErr)
+	h.LastMaintenance = c.lastMaintenanceOK
+	c.mutex.Unlock()
+
+	if !h.LastMaintenance.IsZero() {
+		h.MaintenanceAge = rl.clock.Now().Sub(h.LastMaintenance)
+	}
+	h.Healthy = h.DirWritable && h.LastWriteOK && h.LastRotationOK && h.LastMaintenanceOK
+	return h
+}
+
+func outcome(err error) (bool, string) {
+	if err != nil {
+		return false, err.Error()
+	}
+	return true, ""
+}
+
+// checkWritable creates and removes a temporary file in dir
+func checkWritable(dir string) error {
+	fh, err := ioutil.TempFile(dir, ".health-")
+	if err != nil {
+		return err
+	}
+	fh.Close()
+	return os.Remove(fh.Name())
+}
+
+// HealthHandler returns an http.Handler serving rl.Health() as JSON,
+// with status 200 when healthy and 503 otherwise.
+func HealthHandler(rl *RotateLogs) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		h := rl.Health()
+		w.Header().Set("Content-Type", "application/json")
+		if !h.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(h)
+	})
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package rotatelogs
+
+func freeBytes(dir string) (uint64, bool) {
+	return 0, false
+}
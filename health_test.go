@@ -0,0 +1,166 @@
+package rotatelogs_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+// Neither a maximum age nor a rotation count is required: such a logger
+// keeps every file, and rotating must not be reported as a failure.
+func TestRotateWithoutRetention(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	rl := newLogger(t, dir, rotatelogs.WithClock(clockwork.NewFakeClockAt(testTime)))
+	defer rl.Close()
+	ch, _ := rl.Subscribe(16)
+
+	_, err := rl.Write([]byte("Hello, World\n"))
+	assert.NoError(t, err, "rl.Write should succeed")
+	assert.NoError(t, rl.Rotate(), "rl.Rotate should succeed")
+
+	for len(ch) > 0 {
+		e := <-ch
+		assert.NotEqual(t, rotatelogs.RotationFailedEventType, e.Type(), "no rotation should fail")
+	}
+	assert.EqualValues(t, 0, rl.Stats().RotationFailures)
+	h := rl.Health()
+	assert.True(t, h.LastRotationOK, "the rotation should be healthy")
+	assert.True(t, h.Healthy, "the logger should be healthy")
+}
+
+func TestHealth(t *testing.T) {
+	t.Run("Failed rotation", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		clock := clockwork.NewFakeClockAt(testTime)
+		rl := newLogger(t, dir, rotatelogs.WithClock(clock), rotatelogs.WithRotationTime(1))
+		defer rl.Close()
+		rl.Write([]byte("first\n"))
+
+		// a directory in place of the file of the day the clock moves to
+		// cannot be opened for writing
+		next := filepath.Join(dir, "app-2021-11-16.log")
+		if !assert.NoError(t, os.Mkdir(next, 0755)) {
+			return
+		}
+		clock.Advance(48 * time.Hour)
+		_, err := rl.Write([]byte("second\n"))
+		assert.Error(t, err, "writing should fail")
+
+		h := rl.Health()
+		assert.False(t, h.Healthy, "the logger should be unhealthy")
+		assert.True(t, h.DirWritable)
+		assert.False(t, h.LastRotationOK)
+		assert.NotEmpty(t, h.LastRotationError)
+		assert.False(t, h.LastWriteOK)
+		assert.NotEmpty(t, h.LastWriteError)
+		s := rl.Stats()
+		assert.NotEmpty(t, s.LastError)
+		assert.True(t, clock.Now().Equal(s.LastErrorTime), "the last error should be dated by the clock")
+
+		if !assert.NoError(t, os.Remove(next)) {
+			return
+		}
+		_, err = rl.Write([]byte("third\n"))
+		assert.NoError(t, err, "writing should succeed again")
+		h = rl.Health()
+		assert.True(t, h.Healthy, "the logger should recover")
+		assert.Empty(t, h.LastRotationError)
+		assert.Empty(t, h.LastWriteError)
+	})
+
+	t.Run("Failed maintenance", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		// a file in place of the trash directory makes retention fail
+		old := testTime.AddDate(0, 0, -3)
+		writeLogFile(t, dir, old, "expired\n", old)
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, ".trash"), nil, 0644)) {
+			return
+		}
+		clock := clockwork.NewFakeClockAt(testTime)
+		rl := newLogger(t, dir,
+			rotatelogs.WithClock(clock),
+			rotatelogs.WithMaxAge(1),
+			rotatelogs.WithTrashGracePeriod(time.Hour),
+		)
+		defer rl.Close()
+
+		assert.Error(t, rl.RunMaintenance(), "maintenance should fail")
+		h := rl.Health()
+		assert.False(t, h.Healthy, "the logger should be unhealthy")
+		assert.False(t, h.LastMaintenanceOK)
+		assert.Contains(t, h.LastMaintenanceError, ".trash")
+		assert.True(t, h.LastMaintenance.IsZero(), "no run has succeeded yet")
+		assert.Zero(t, h.MaintenanceAge)
+		assert.False(t, rl.Stats().LastErrorTime.IsZero(), "the time of the error should be recorded")
+
+		if !assert.NoError(t, os.Remove(filepath.Join(dir, ".trash"))) {
+			return
+		}
+		assert.NoError(t, rl.RunMaintenance(), "maintenance should succeed")
+		clock.Advance(time.Minute)
+		h = rl.Health()
+		assert.True(t, h.Healthy, "the logger should recover")
+		assert.False(t, h.LastMaintenance.IsZero())
+		assert.True(t, h.MaintenanceAge > 0 && h.MaintenanceAge <= time.Minute, "the age should follow the clock, got %v", h.MaintenanceAge)
+	})
+
+	t.Run("Directory removed", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		rl := newLogger(t, dir, rotatelogs.WithClock(clockwork.NewFakeClockAt(testTime)))
+		defer rl.Close()
+
+		if !assert.NoError(t, os.RemoveAll(dir)) {
+			return
+		}
+		h := rl.Health()
+		assert.False(t, h.Healthy, "the logger should be unhealthy")
+		assert.False(t, h.DirWritable)
+		assert.NotEmpty(t, h.DirError)
+	})
+}
+
+func TestHealthHandler(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	rl := newLogger(t, dir, rotatelogs.WithClock(clockwork.NewFakeClockAt(testTime)))
+	defer rl.Close()
+	rl.Write([]byte("first\n"))
+	handler := rotatelogs.HealthHandler(rl)
+
+	serve := func() (*httptest.ResponseRecorder, rotatelogs.Health) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		var h rotatelogs.Health
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &h), "the body should be JSON")
+		return rec, h
+	}
+
+	rec, h := serve()
+	assert.Equal(t, http.StatusOK, rec.Code, "a healthy logger should answer 200")
+	assert.True(t, h.Healthy)
+	assert.Equal(t, dir, h.Directory)
+
+	if !assert.NoError(t, os.RemoveAll(dir)) {
+		return
+	}
+	rec, h = serve()
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code, "an unhealthy logger should answer 503")
+	assert.False(t, h.Healthy)
+	assert.False(t, h.DirWritable)
+	assert.NotEmpty(t, h.DirError)
+}
@@ -0,0 +1,61 @@
+package rotatelogs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+	"github.com/stretchr/testify/assert"
+)
+
+// testTime is the time the fake clocks of the tests start at. It is in
+// the local zone because file names are generated from local dates.
+var testTime = time.Date(2021, 11, 14, 12, 0, 0, 0, time.Local)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "file-rotatelogs-test")
+	if !assert.NoError(t, err, "creating temporary directory should succeed") {
+		t.FailNow()
+	}
+	return dir
+}
+
+// newLogger creates a logger named "app" writing to dir, delivering its
+// events synchronously so tests can observe them without waiting.
+func newLogger(t *testing.T, dir string, options ...rotatelogs.Option) *rotatelogs.RotateLogs {
+	options = append([]rotatelogs.Option{
+		rotatelogs.WithFilePath(dir + string(filepath.Separator)),
+		rotatelogs.WithFileName("app"),
+		rotatelogs.WithEventDelivery(rotatelogs.SyncDelivery),
+	}, options...)
+	rl, err := rotatelogs.New(options...)
+	if !assert.NoError(t, err, "rotatelogs.New should succeed") {
+		t.FailNow()
+	}
+	return rl
+}
+
+// writeLogFile creates a log file of the logger named "app" for the day
+// of date, with the given content and modification time.
+func writeLogFile(t *testing.T, dir string, date time.Time, content string, mtime time.Time) string {
+	path := filepath.Join(dir, "app-"+date.Format("2006-01-02")+".log")
+	if !assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644), "writing %s should succeed", path) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, os.Chtimes(path, mtime, mtime), "setting the times of %s should succeed", path) {
+		t.FailNow()
+	}
+	return path
+}
+
+// assertNoFile asserts that nothing exists at path.
+func assertNoFile(t *testing.T, path string, msgAndArgs ...interface{}) bool {
+	_, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return true
+	}
+	return assert.Fail(t, "file "+path+" exists", msgAndArgs...)
+}
@@ -1,11 +1,14 @@
 package rotatelogs
 
 import (
+	"io"
 	"os"
 	"sync"
 	"time"
 
+	"github.com/chriszhangmq/file-rotatelogs/internal/fileutil"
 	strftime "github.com/lestrrat-go/strftime"
+	"github.com/robfig/cron"
 )
 
 type Handler interface {
@@ -23,35 +26,213 @@ type EventType int
 const (
 	InvalidEventType EventType = iota
 	FileRotatedEventType
+	FileCreatedEventType
+	FileCompressedEventType
+	FileDeletedEventType
+	RotationFailedEventType
+	CompressionFailedEventType
+	MaintenanceCompletedEventType
+	CommandCompletedEventType
+	FileShippedEventType
+	ShippingFailedEventType
+	FileReopenedEventType
+)
+
+// HeaderFunc writes the header of a newly created log file. It is not
+// called when the object appends to a file that already has content.
+type HeaderFunc func(w io.Writer, filename string) error
+
+// FooterFunc writes the trailer of a log file right before the object
+// rotates away from it or is closed. A file appended to again after a
+// restart then holds a footer per run; see WithFooterOnClose to only
+// write it on rotation.
+type FooterFunc func(w io.Writer, summary FileSummary) error
+
+// FileSummary describes what the object wrote to a log file
+type FileSummary struct {
+	Filename string
+	Bytes    int64     // bytes written by this object, header included
+	Writes   uint64    // number of Write calls
+	Start    time.Time // when the object opened the file
+	End      time.Time // last write, or Start if there was none
+}
+
+// AgeSource selects where retention takes the age of a log file from
+type AgeSource int
+
+const (
+	// AgeFromFileName uses the date encoded in the file name, falling
+	// back to the modification time when the name cannot be parsed
+	AgeFromFileName AgeSource = iota
+	// AgeFromModTime uses the modification time of the file
+	AgeFromModTime
+)
+
+// RotationReason tells why a FileRotatedEvent happened
+type RotationReason int
+
+const (
+	// RotationReasonStartup is the first file opened by the object
+	RotationReasonStartup RotationReason = iota
+	// RotationReasonTime means the rotation time boundary was crossed
+	RotationReasonTime
+	// RotationReasonSize means the current file reached the rotation size
+	RotationReasonSize
+	// RotationReasonManual means Rotate was called
+	RotationReasonManual
+	// RotationReasonFileMissing means the current file disappeared
+	RotationReasonFileMissing
+)
+
+// ReopenReason tells why a FileReopenedEvent happened
+type ReopenReason int
+
+const (
+	// ReopenReasonManual means Reopen was called
+	ReopenReasonManual ReopenReason = iota
+	// ReopenReasonReplaced means another file now has the current file's
+	// name, e.g. logrotate renamed it and created a new one
+	ReopenReasonReplaced
+	// ReopenReasonTruncated means the current file shrank, e.g. it was
+	// truncated by logrotate's copytruncate
+	ReopenReasonTruncated
 )
 
 type FileRotatedEvent struct {
-	prev    string // previous filename
-	current string // current, new filename
+	prev       string         // previous filename
+	current    string         // current, new filename
+	time       time.Time      // when the rotation happened
+	reason     RotationReason // why the rotation happened
+	prevSize   int64          // final size of the previous file
+	prevWrites uint64         // number of writes to the previous file
+	start      time.Time      // when the previous file was opened
+	end        time.Time      // last write to the previous file
+}
+
+type FileCreatedEvent struct {
+	file string    // created filename
+	time time.Time // when the file was created
+}
+
+type FileCompressedEvent struct {
+	source         string        // filename before compression
+	file           string        // compressed filename
+	originalSize   int64         // size of the source file
+	compressedSize int64         // size of the compressed file
+	duration       time.Duration // time spent compressing
+	time           time.Time     // when the compression finished
+}
+
+type FileDeletedEvent struct {
+	file        string    // deleted filename
+	size        int64     // size of the deleted file
+	quarantined bool      // whether the file was moved to the trash
+	retention   bool      // whether the retention policy deleted the file
+	time        time.Time // when the file was deleted
+}
+
+type RotationFailedEvent struct {
+	file string    // filename that could not be rotated to
+	err  error     // cause of the failure
+	time time.Time // when the failure happened
+}
+
+type CompressionFailedEvent struct {
+	file string    // filename that could not be compressed
+	err  error     // cause of the failure
+	time time.Time // when the failure happened
+}
+
+type CommandCompletedEvent struct {
+	command  string        // executable that was run
+	file     string        // file passed to the command
+	trigger  EventType     // event type that triggered the command
+	stdout   []byte        // captured standard output
+	stderr   []byte        // captured standard error
+	exitCode int           // exit status, -1 if the command did not exit
+	err      error         // error returned while running the command
+	duration time.Duration // time the command took
+	time     time.Time     // when the command completed
+}
+
+type FileShippedEvent struct {
+	file     string    // shipped filename
+	key      string    // object key in the store
+	attempts int       // number of attempts it took
+	deleted  bool      // whether the local file was removed
+	time     time.Time // when the upload completed
+}
+
+type ShippingFailedEvent struct {
+	file     string    // filename that could not be shipped
+	key      string    // object key in the store
+	attempts int       // number of attempts so far
+	gaveUp   bool      // whether the shipper stopped retrying
+	err      error     // cause of the failure
+	time     time.Time // when the failure happened
+}
+
+type FileReopenedEvent struct {
+	file   string       // reopened filename
+	reason ReopenReason // why the file was reopened
+	size   int64        // size of the file when it was reopened
+	time   time.Time    // when the file was reopened
+}
+
+type MaintenanceCompletedEvent struct {
+	start      time.Time     // when the maintenance run started
+	duration   time.Duration // time spent on the run
+	compressed int           // number of files compressed
+	deleted    int           // number of files deleted or quarantined
+	err        error         // first error encountered, if any
 }
 
 // RotateLogs represents a log file that gets
 // automatically rotated as you write to it.
 type RotateLogs struct {
-	clock          Clock
-	curFn          string
-	curBaseFn      string
-	globLogPattern string
-	generation     int
-	linkName       string
-	maxAge         time.Duration
-	mutex          sync.RWMutex
-	eventHandler   Handler
-	outFh          *os.File
-	pattern        *strftime.Strftime
-	rotationTime   time.Duration
-	rotationSize   int64
-	rotationCount  uint
-	forceNewFile   bool
-	filePath       string
-	fileName       string
-	compressFile   bool
-	cronTime       string
+	clock         Clock
+	curFn         string
+	curBaseFn     string
+	matcher       *fileutil.FileMatcher
+	generation    int
+	linkName      string
+	maxAge        time.Duration
+	ageSource     AgeSource
+	mutex         sync.RWMutex
+	events        *dispatcher
+	diag          *diagOutput
+	postRotate    *commandRunner
+	shipper       *shipper
+	stats         statsCollector
+	header        HeaderFunc
+	footer        FooterFunc
+	footerOnClose bool
+	outFh         *os.File
+	pattern       *strftime.Strftime
+	rotationTime  time.Duration
+	rotationSize  int64
+	rotationCount uint
+	forceNewFile  bool
+	filePath      string
+	fileName      string
+	compressFile  bool
+	cronTime      string
+	cron          *cron.Cron
+	closeHooks    []*closeHook
+	closed        bool
+
+	// statistics of the file currently written to
+	curBytes     int64
+	curSize      int64 // size the current file has at least, to detect truncation
+	curWrites    uint64
+	curStart     time.Time
+	curLastWrite time.Time
+
+	trashGracePeriod time.Duration
+	deleteHook       DeleteHook
+	vetoes           vetoTracker
+	pins             pinSet
+	trash            trashIndex
 }
 
 // Clock is the interface used by the RotateLogs
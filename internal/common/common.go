@@ -11,3 +11,7 @@ const Space = " "
 const IsNull = ""
 const TimeFormat = "2006-01-02"
 const FileSuffix = ".log"
+const TrashDir = ".trash"
+const PinsSuffix = ".pins"
+const ShipperJournalSuffix = ".shipper.json"
+const TrashIndexSuffix = ".trash.json"
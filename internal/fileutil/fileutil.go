@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"github.com/chriszhangmq/file-rotatelogs/internal/common"
 	"io"
-	"log"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -48,7 +50,7 @@ func GenerateFn(pattern *strftime.Strftime, clock interface{ Now() time.Time },
 	return pattern.FormatString(base)
 }
 
-//产生新的文件名（用于按大小分割文件）
+// 产生新的文件名（用于按大小分割文件）
 func GenerateFileNme(path string, name string, suffix string, clock interface{ Now() time.Time }, timeFormat string) string {
 	now := clock.Now()
 
@@ -65,7 +67,7 @@ func GenerateFileNme(path string, name string, suffix string, clock interface{ N
 	return fileName
 }
 
-//产生新的文件名（用于按大小分割文件）
+// 产生新的文件名（用于按大小分割文件）
 func GenerateFnForFileSize(pattern *strftime.Strftime, clock interface{ Now() time.Time }) string {
 	now := clock.Now()
 
@@ -97,51 +99,9 @@ func CreateFile(filename string) (*os.File, error) {
 	return fh, nil
 }
 
-func ParseTimeFromFileName(fileNameTimeFormat string, fileName string, clock time.Time) time.Time {
-	//正则表达式：获取时间字符串
-	fileNameTime := getTimeFromStr(fileName)
-	if len(fileNameTime) <= 0 || fileNameTime == common.IsNull {
-		return time.Time{}
-	}
-	//字符串转换为时间
-	var err error
-	var fileNameInTime time.Time
-	//当前时间区域
-	if clock.Location() != time.UTC {
-		fileNameInTime, err = time.ParseInLocation(fileNameTimeFormat, fileNameTime, time.Local)
-	} else {
-		fileNameInTime, err = time.Parse(fileNameTimeFormat, fileNameTime)
-	}
-	if err != nil {
-		log.Fatal(err)
-	}
-	return fileNameInTime
-}
-
-func getTimeFromStr(str string) string {
-	planRegx := regexp.MustCompile("([1-9])([0-9]|[ ]|[-]|[:]|[T])+")
-	subs := planRegx.FindStringSubmatch(str)
-	if len(subs) > 0 {
-		return strings.TrimSpace(subs[0])
-	}
-	return ""
-}
-
-func CompressLogFiles(compressFile []string, filePath string) {
-	for _, f := range compressFile {
-		fn := filepath.Join(dir(filePath), f)
-		errCompress := compressLogFile(fn, fn+common.CompressSuffix)
-		if errCompress != nil {
-			log.Println(errCompress)
-		} else {
-			if err := os.Remove(f); err != nil {
-				log.Println(err)
-			}
-		}
-	}
-}
-
-func compressLogFile(src, dst string) (err error) {
+// CompressLogFile gzips src into dst and removes src once the compressed
+// file has been written successfully.
+func CompressLogFile(src, dst string) (err error) {
 	f, err := os.Open(src)
 	if err != nil {
 		return fmt.Errorf("failed to open log file: %v", err)
@@ -198,18 +158,6 @@ func chown(_ string, _ os.FileInfo) error {
 	return nil
 }
 
-func dir(filePath string) string {
-	return filepath.Dir(filename(filePath))
-}
-
-func filename(filePath string) string {
-	if filePath != common.IsNull {
-		return filePath
-	}
-	name := filepath.Base(os.Args[0]) + "-lumberjack.log"
-	return filepath.Join(os.TempDir(), name)
-}
-
 func GetNewFileName(filePath string, fileName string, rotationSize int64, clock interface{ Now() time.Time }) string {
 	index := 1
 	newFileName := common.IsNull
@@ -242,3 +190,139 @@ func GetNewFileName(filePath string, fileName string, rotationSize int64, clock
 		}
 	}
 }
+
+// WriteFileAtomic writes data to a temporary file next to filename and
+// renames it into place, so readers never observe a partial file.
+func WriteFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	tmp := filename + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, perm); err != nil {
+		return errors.Wrapf(err, "failed to write %s", tmp)
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return errors.Wrapf(err, "failed to rename %s", tmp)
+	}
+	return nil
+}
+
+// GetNextFileName returns the first file name of the current day that does
+// not exist yet: the dated file itself or its next numbered part. It is used
+// when a rotation is requested regardless of the size of the current file.
+func GetNextFileName(filePath string, fileName string, clock interface{ Now() time.Time }) string {
+	newFileName := GenerateFileNme(filePath, fileName, common.FileSuffix, clock, common.TimeFormat)
+	if _, err := os.Stat(newFileName); err != nil {
+		return newFileName
+	}
+	for index := 1; ; index++ {
+		partFileName := fmt.Sprintf("%s.%d%s", newFileName, index, common.FileSuffix)
+		if _, err := os.Stat(partFileName); err != nil {
+			return partFileName
+		}
+	}
+}
+
+// FileMatcher recognises the files that belong to a single logger. A file is
+// owned by the logger only when its base name is exactly
+//
+//	<name>-<date>.log[.<part>.log][.gz|_lock|_symlink]
+//
+// so loggers whose names share a prefix (e.g. "app" and "app-api") living in
+// the same directory never pick up each other's files.
+type FileMatcher struct {
+	dir    string
+	layout string
+	re     *regexp.Regexp
+}
+
+// NewFileMatcher creates a FileMatcher for the files generated from filePath,
+// fileName and the date layout timeFormat.
+func NewFileMatcher(filePath string, fileName string, timeFormat string) *FileMatcher {
+	prefix := filePath + fileName
+	var date strings.Builder
+	for _, c := range timeFormat {
+		if c >= '0' && c <= '9' {
+			date.WriteString(`[0-9]`)
+			continue
+		}
+		date.WriteString(regexp.QuoteMeta(string(c)))
+	}
+	suffix := regexp.QuoteMeta(common.FileSuffix)
+	expr := fmt.Sprintf(`^%s-(%s)%s(?:\.([0-9]+)%s)?(%s|%s|%s)?$`,
+		regexp.QuoteMeta(filepath.Base(prefix)),
+		date.String(),
+		suffix,
+		suffix,
+		regexp.QuoteMeta(common.CompressSuffix),
+		regexp.QuoteMeta(common.LockSuffix),
+		regexp.QuoteMeta(common.SymlinkSuffix),
+	)
+	return &FileMatcher{
+		dir:    filepath.Dir(prefix),
+		layout: timeFormat,
+		re:     regexp.MustCompile(expr),
+	}
+}
+
+// Dir returns the directory the matched files live in.
+func (m *FileMatcher) Dir() string {
+	return m.dir
+}
+
+// Match reports whether the base name of path belongs to the logger.
+func (m *FileMatcher) Match(path string) bool {
+	return m.re.MatchString(filepath.Base(path))
+}
+
+// ParseTime returns the date encoded in the name of a file belonging to
+// the logger, interpreted in loc.
+func (m *FileMatcher) ParseTime(path string, loc *time.Location) (time.Time, error) {
+	subs := m.re.FindStringSubmatch(filepath.Base(path))
+	if subs == nil {
+		return time.Time{}, errors.Errorf("%s is not a managed log file", path)
+	}
+	t, err := time.ParseInLocation(m.layout, subs[1], loc)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "failed to parse time from %s", path)
+	}
+	return t, nil
+}
+
+// Part returns the part number of a file belonging to the logger: 0 for
+// the first file of a day, N for "<name>-<date>.log.N.log", and -1 for
+// files that do not belong to the logger.
+func (m *FileMatcher) Part(path string) int {
+	subs := m.re.FindStringSubmatch(filepath.Base(path))
+	if subs == nil {
+		return -1
+	}
+	if subs[2] == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(subs[2])
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// Glob returns the sorted paths of every file in the logger's directory that
+// belongs to the logger.
+func (m *FileMatcher) Glob() ([]string, error) {
+	d, err := os.Open(m.dir)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	names, err := d.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]string, 0, len(names))
+	for _, name := range names {
+		if m.re.MatchString(name) {
+			matches = append(matches, filepath.Join(m.dir, name))
+		}
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
@@ -2,6 +2,9 @@ package fileutil_test
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -36,3 +39,127 @@ func TestGenerateFn(t *testing.T) {
 		}
 	}
 }
+
+func TestFileMatcher(t *testing.T) {
+	m := fileutil.NewFileMatcher("/var/log/", "app", "2006-01-02")
+
+	owned := []string{
+		"app-2021-11-14.log",
+		"app-2021-11-14.log.3.log",
+		"app-2021-11-14.log.gz",
+		"app-2021-11-14.log.12.log.gz",
+		"app-2021-11-14.log_lock",
+		"/var/log/app-2021-11-14.log_symlink",
+	}
+	for _, name := range owned {
+		assert.True(t, m.Match(name), "%s should belong to app", name)
+	}
+
+	foreign := []string{
+		"app",
+		"app-api-2021-11-14.log",
+		"app-api-2021-11-14.log.gz",
+		"app-2021-11-14",
+		"app-2021-11-14.log.bak",
+		"app-2021-11-14.log.x.log",
+		"app-20211114.log",
+		"myapp-2021-11-14.log",
+		"xapp-2021-11-14.log",
+	}
+	for _, name := range foreign {
+		assert.False(t, m.Match(name), "%s should not belong to app", name)
+	}
+}
+
+func TestFileMatcherGlob(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-rotatelogs-matcher")
+	if !assert.NoError(t, err, "creating temporary directory should succeed") {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	files := []string{
+		"app-2021-11-13.log.gz",
+		"app-2021-11-14.log",
+		"app-2021-11-14.log.1.log",
+		"app-api-2021-11-13.log.gz",
+		"app-api-2021-11-14.log",
+		"app.log",
+	}
+	for _, name := range files {
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), nil, 0644)) {
+			return
+		}
+	}
+
+	app, err := fileutil.NewFileMatcher(dir+"/", "app", "2006-01-02").Glob()
+	if !assert.NoError(t, err, "Glob should succeed") {
+		return
+	}
+	assert.Equal(t, []string{
+		filepath.Join(dir, "app-2021-11-13.log.gz"),
+		filepath.Join(dir, "app-2021-11-14.log"),
+		filepath.Join(dir, "app-2021-11-14.log.1.log"),
+	}, app)
+
+	api, err := fileutil.NewFileMatcher(dir+"/", "app-api", "2006-01-02").Glob()
+	if !assert.NoError(t, err, "Glob should succeed") {
+		return
+	}
+	assert.Equal(t, []string{
+		filepath.Join(dir, "app-api-2021-11-13.log.gz"),
+		filepath.Join(dir, "app-api-2021-11-14.log"),
+	}, api)
+}
+
+func TestFileMatcherParseTime(t *testing.T) {
+	m := fileutil.NewFileMatcher("/var/log/", "app", "2006-01-02")
+
+	for _, name := range []string{"app-2021-11-14.log", "/var/log/app-2021-11-14.log.2.log.gz"} {
+		got, err := m.ParseTime(name, time.UTC)
+		if !assert.NoError(t, err, "ParseTime(%s) should succeed", name) {
+			return
+		}
+		assert.Equal(t, time.Date(2021, 11, 14, 0, 0, 0, 0, time.UTC), got)
+	}
+
+	for _, name := range []string{"app-2021-13-45.log", "app-api-2021-11-14.log"} {
+		_, err := m.ParseTime(name, time.UTC)
+		assert.Error(t, err, "ParseTime(%s) should fail", name)
+	}
+}
+
+func TestFileMatcherPart(t *testing.T) {
+	m := fileutil.NewFileMatcher("/var/log/", "app", "2006-01-02")
+
+	parts := map[string]int{
+		"app-2021-11-14.log":                    0,
+		"app-2021-11-14.log.gz":                 0,
+		"app-2021-11-14.log.2.log":              2,
+		"/var/log/app-2021-11-14.log.12.log.gz": 12,
+		"app-api-2021-11-14.log.2.log":          -1,
+	}
+	for name, want := range parts {
+		assert.Equal(t, want, m.Part(name), "Part(%s)", name)
+	}
+}
+
+func TestCompressLogFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-rotatelogs-compress")
+	if !assert.NoError(t, err, "creating temporary directory should succeed") {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "app-2021-11-14.log")
+	if !assert.NoError(t, ioutil.WriteFile(src, []byte("Hello, World"), 0644)) {
+		return
+	}
+	if !assert.NoError(t, fileutil.CompressLogFile(src, src+".gz"), "CompressLogFile should succeed") {
+		return
+	}
+
+	_, err = os.Stat(src)
+	assert.True(t, os.IsNotExist(err), "source file should be removed")
+	assert.FileExists(t, src+".gz")
+}
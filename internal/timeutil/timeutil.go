@@ -18,14 +18,6 @@ func IsToday(currTime time.Time, todayTime time.Time) bool {
 	return false
 }
 
-func IsMaxDay(cutOffTime time.Time, fileTime time.Time) bool {
-	cutOffDateString := cutOffTime.Format(common.TimeFormat)
-	cutOffDate, _ := time.Parse(common.TimeFormat, cutOffDateString)
-	fileDateString := fileTime.Format(common.TimeFormat)
-	fileDate, _ := time.Parse(common.TimeFormat, fileDateString)
-	return fileDate.Before(cutOffDate)
-}
-
 func CompareTimeWithDay(cutOffTime time.Time, fileTime time.Time) bool {
 	cutOffDateString := cutOffTime.Format(common.TimeFormat)
 	cutOffDate, _ := time.Parse(common.TimeFormat, cutOffDateString)
@@ -0,0 +1,85 @@
+// Package units parses the human readable sizes and durations used in
+// configuration files.
+package units
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var sizeUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// ParseSize parses a byte size such as "512MiB", "1.5GB" or "1024".
+// Units are case insensitive; KB, MB, GB and TB are decimal, KiB, MiB,
+// GiB and TiB binary, and a bare number is a number of bytes.
+func ParseSize(s string) (int64, error) {
+	str := strings.TrimSpace(s)
+	i := strings.IndexFunc(str, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(str)
+	}
+	num, unit := str[:i], strings.ToLower(strings.TrimSpace(str[i:]))
+
+	mult, ok := sizeUnits[unit]
+	if !ok {
+		return 0, errors.Errorf("invalid size %q: unknown unit %q", s, str[i:])
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, errors.Errorf("invalid size %q", s)
+	}
+	v *= mult
+	if v >= math.MaxInt64 {
+		return 0, errors.Errorf("invalid size %q: too large", s)
+	}
+	return int64(v), nil
+}
+
+// FormatSize formats n with the largest binary unit that divides it, so
+// that ParseSize(FormatSize(n)) == n.
+func FormatSize(n int64) string {
+	for _, u := range []struct {
+		name string
+		size int64
+	}{{"TiB", 1 << 40}, {"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10}} {
+		if n != 0 && n%u.size == 0 {
+			return strconv.FormatInt(n/u.size, 10) + u.name
+		}
+	}
+	return strconv.FormatInt(n, 10) + "B"
+}
+
+// ParseDuration parses a duration like time.ParseDuration, and also
+// accepts a whole number of days such as "7d".
+func ParseDuration(s string) (time.Duration, error) {
+	str := strings.TrimSpace(s)
+	if strings.HasSuffix(str, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(str, "d"))
+		if err != nil {
+			return 0, errors.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(str)
+	if err != nil {
+		return 0, errors.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,65 @@
+package units_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chriszhangmq/file-rotatelogs/internal/units"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSize(t *testing.T) {
+	valid := map[string]int64{
+		"1024":    1024,
+		"10B":     10,
+		"512MiB":  512 << 20,
+		"512mib":  512 << 20,
+		"1.5GiB":  3 << 29,
+		"100MB":   100000000,
+		" 2 KiB ": 2048,
+	}
+	for s, want := range valid {
+		got, err := units.ParseSize(s)
+		if !assert.NoError(t, err, "ParseSize(%q) should succeed", s) {
+			continue
+		}
+		assert.Equal(t, want, got, "ParseSize(%q)", s)
+	}
+
+	for _, s := range []string{"", "MiB", "12XB", "1.2.3MB", "-1MB", "99999999TiB"} {
+		_, err := units.ParseSize(s)
+		assert.Error(t, err, "ParseSize(%q) should fail", s)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for _, n := range []int64{0, 1, 1000, 1024, 512 << 20, 3 << 29, 5 << 40} {
+		got, err := units.ParseSize(units.FormatSize(n))
+		if !assert.NoError(t, err, "FormatSize(%d) should parse", n) {
+			continue
+		}
+		assert.Equal(t, n, got)
+	}
+	assert.Equal(t, "512MiB", units.FormatSize(512<<20))
+}
+
+func TestParseDuration(t *testing.T) {
+	valid := map[string]time.Duration{
+		"36h":   36 * time.Hour,
+		"1h30m": 90 * time.Minute,
+		"7d":    7 * 24 * time.Hour,
+		" 0s ":  0,
+	}
+	for s, want := range valid {
+		got, err := units.ParseDuration(s)
+		if !assert.NoError(t, err, "ParseDuration(%q) should succeed", s) {
+			continue
+		}
+		assert.Equal(t, want, got, "ParseDuration(%q)", s)
+	}
+
+	for _, s := range []string{"", "7", "1.5d", "d", "soon"} {
+		_, err := units.ParseDuration(s)
+		assert.Error(t, err, "ParseDuration(%q) should fail", s)
+	}
+}
@@ -0,0 +1,15 @@
+module github.com/chriszhangmq/file-rotatelogs/logrusrotate
+
+go 1.13
+
+require (
+	github.com/chriszhangmq/file-rotatelogs v0.0.0-20261018134719-a84fb65ff66a
+	github.com/pkg/errors v0.8.1
+	github.com/robfig/cron v1.2.0
+	github.com/sirupsen/logrus v1.9.3
+	github.com/stretchr/testify v1.8.1
+)
+
+// builds inside the repository use the module next door; the
+// requirement above is what consumers of this module resolve
+replace github.com/chriszhangmq/file-rotatelogs => ../
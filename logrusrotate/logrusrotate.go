@@ -0,0 +1,154 @@
+// Package logrusrotate integrates RotateLogs with github.com/sirupsen/logrus.
+package logrusrotate
+
+import (
+	"sync"
+	"time"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+	"github.com/pkg/errors"
+	"github.com/robfig/cron"
+	"github.com/sirupsen/logrus"
+)
+
+// Route sends entries whose level is in Levels to Logs, formatted with
+// Formatter. A nil Formatter uses a logrus.TextFormatter without colors.
+type Route struct {
+	Levels    []logrus.Level
+	Logs      *rotatelogs.RotateLogs
+	Formatter logrus.Formatter
+}
+
+// LevelsFrom returns min and every more severe level, e.g.
+// LevelsFrom(logrus.ErrorLevel) is error, fatal and panic.
+func LevelsFrom(min logrus.Level) []logrus.Level {
+	levels := make([]logrus.Level, 0, len(logrus.AllLevels))
+	for _, l := range logrus.AllLevels {
+		if l <= min {
+			levels = append(levels, l)
+		}
+	}
+	return levels
+}
+
+// Hook is a logrus.Hook writing entries to rotating files by level.
+// Since the hook does the writing, the logger's own Out is usually set
+// to ioutil.Discard.
+type Hook struct {
+	routes  []Route
+	byLevel map[logrus.Level][]int
+	levels  []logrus.Level
+
+	mutex sync.Mutex
+	cron  *cron.Cron
+}
+
+// NewHook creates a Hook from routes. Several routes may share a level,
+// in which case the entry is written to each of them.
+func NewHook(routes ...Route) *Hook {
+	h := &Hook{byLevel: make(map[logrus.Level][]int)}
+	for _, r := range routes {
+		if r.Formatter == nil {
+			r.Formatter = &logrus.TextFormatter{DisableColors: true}
+		}
+		h.routes = append(h.routes, r)
+		for _, l := range r.Levels {
+			if _, ok := h.byLevel[l]; !ok {
+				h.levels = append(h.levels, l)
+			}
+			h.byLevel[l] = append(h.byLevel[l], len(h.routes)-1)
+		}
+	}
+	return h
+}
+
+// Levels implements logrus.Hook.
+func (h *Hook) Levels() []logrus.Level {
+	return h.levels
+}
+
+// Fire implements logrus.Hook. Every route for the entry's level is
+// attempted, and the first error is returned.
+func (h *Hook) Fire(entry *logrus.Entry) error {
+	var first error
+	for _, i := range h.byLevel[entry.Level] {
+		r := h.routes[i]
+		b, err := r.Formatter.Format(entry)
+		if err == nil {
+			_, err = r.Logs.Write(b)
+		}
+		if err != nil && first == nil {
+			first = errors.Wrapf(err, "failed to write %s entry", entry.Level)
+		}
+	}
+	return first
+}
+
+// logs returns each distinct RotateLogs of the hook once.
+func (h *Hook) logs() []*rotatelogs.RotateLogs {
+	seen := make(map[*rotatelogs.RotateLogs]struct{}, len(h.routes))
+	list := make([]*rotatelogs.RotateLogs, 0, len(h.routes))
+	for _, r := range h.routes {
+		if _, ok := seen[r.Logs]; ok {
+			continue
+		}
+		seen[r.Logs] = struct{}{}
+		list = append(list, r.Logs)
+	}
+	return list
+}
+
+// RunMaintenance runs retention and compression for every file of the
+// hook, one after another, and returns the first error.
+func (h *Hook) RunMaintenance() error {
+	var first error
+	for _, rl := range h.logs() {
+		if err := rl.RunMaintenance(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// StartMaintenance schedules RunMaintenance with the cron spec, e.g.
+// "0 0 * * * *", so that all the files of the hook share one scheduler.
+// The RotateLogs instances should then be created without WithCronTime.
+// The spec is evaluated in the Location of the first file of the hook.
+// The schedule stops on Close.
+func (h *Hook) StartMaintenance(spec string) error {
+	loc := time.Local
+	if logs := h.logs(); len(logs) > 0 {
+		loc = logs[0].Location()
+	}
+	c := cron.NewWithLocation(loc)
+	if err := c.AddFunc(spec, func() { h.RunMaintenance() }); err != nil {
+		return errors.Wrapf(err, "invalid cron spec %q", spec)
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.cron != nil {
+		return errors.New("maintenance is already scheduled")
+	}
+	h.cron = c
+	c.Start()
+	return nil
+}
+
+// Close stops the maintenance schedule and closes every file of the hook.
+func (h *Hook) Close() error {
+	h.mutex.Lock()
+	if h.cron != nil {
+		h.cron.Stop()
+		h.cron = nil
+	}
+	h.mutex.Unlock()
+
+	var first error
+	for _, rl := range h.logs() {
+		if err := rl.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
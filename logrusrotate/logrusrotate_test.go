@@ -0,0 +1,112 @@
+package logrusrotate_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+	"github.com/chriszhangmq/file-rotatelogs/logrusrotate"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHook(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-rotatelogs-logrus")
+	if !assert.NoError(t, err, "creating temporary directory should succeed") {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	newLogs := func(name string) *rotatelogs.RotateLogs {
+		rl, err := rotatelogs.New(
+			rotatelogs.WithFilePath(dir+"/"),
+			rotatelogs.WithFileName(name),
+			rotatelogs.WithMaxAge(7),
+		)
+		if !assert.NoError(t, err, `rotatelogs.New should succeed`) {
+			t.FailNow()
+		}
+		return rl
+	}
+	all := newLogs("app")
+	errs := newLogs("app-error")
+
+	hook := logrusrotate.NewHook(
+		logrusrotate.Route{Levels: logrus.AllLevels, Logs: all},
+		logrusrotate.Route{Levels: logrusrotate.LevelsFrom(logrus.ErrorLevel), Logs: errs, Formatter: &logrus.JSONFormatter{}},
+	)
+	defer hook.Close()
+	if !assert.NoError(t, hook.StartMaintenance("@daily"), "StartMaintenance should succeed") {
+		return
+	}
+	assert.Error(t, hook.StartMaintenance("@daily"), "a second schedule should be rejected")
+
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.AddHook(hook)
+	logger.Info("info message")
+	logger.Error("error message")
+
+	content, err := ioutil.ReadFile(all.CurrentFileName())
+	if !assert.NoError(t, err, "reading the main file should succeed") {
+		return
+	}
+	assert.Contains(t, string(content), "info message")
+	assert.Contains(t, string(content), "error message")
+
+	content, err = ioutil.ReadFile(errs.CurrentFileName())
+	if !assert.NoError(t, err, "reading the error file should succeed") {
+		return
+	}
+	assert.NotContains(t, string(content), "info message")
+	assert.Contains(t, string(content), `"msg":"error message"`)
+
+	assert.NoError(t, hook.RunMaintenance(), "RunMaintenance should succeed")
+}
+
+func TestStartMaintenanceLocation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-rotatelogs-logrus")
+	if !assert.NoError(t, err, "creating temporary directory should succeed") {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	// a zone whose hour differs from the local one, so that the schedule
+	// only fires if it is evaluated in that zone
+	_, offset := time.Now().Zone()
+	loc := time.FixedZone("logrus-test", offset+12*60*60)
+	now := time.Now().In(loc)
+	if now.Minute() == 59 && now.Second() > 55 {
+		time.Sleep(5 * time.Second)
+		now = time.Now().In(loc)
+	}
+
+	var runs int32
+	rl, err := rotatelogs.New(
+		rotatelogs.WithFilePath(dir+"/"),
+		rotatelogs.WithFileName("app"),
+		rotatelogs.WithLocation(loc),
+		rotatelogs.WithHandler(rotatelogs.HandlerFunc(func(e rotatelogs.Event) {
+			if e.Type() == rotatelogs.MaintenanceCompletedEventType {
+				atomic.AddInt32(&runs, 1)
+			}
+		})),
+	)
+	if !assert.NoError(t, err, `rotatelogs.New should succeed`) {
+		return
+	}
+	hook := logrusrotate.NewHook(logrusrotate.Route{Levels: logrus.AllLevels, Logs: rl})
+	defer hook.Close()
+	if !assert.NoError(t, hook.StartMaintenance(fmt.Sprintf("* * %d * * *", now.Hour())), "StartMaintenance should succeed") {
+		return
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); atomic.LoadInt32(&runs) < 1 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(t, atomic.LoadInt32(&runs) >= 1, "the schedule should fire in the location of the files")
+}
@@ -0,0 +1,243 @@
+package rotatelogs
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/chriszhangmq/file-rotatelogs/internal/common"
+	"github.com/pkg/errors"
+	"github.com/robfig/cron"
+)
+
+// Manager owns a set of named RotateLogs objects and runs their
+// maintenance from a single scheduler, instead of one cron per object.
+type Manager struct {
+	mutex    sync.RWMutex
+	loggers  map[string]*RotateLogs
+	defaults []Option
+	cron     *cron.Cron
+	closed   bool
+
+	// maintenance runs in progress, waited for by CloseAll
+	jobs sync.WaitGroup
+
+	// set while a scheduled maintenance run is in progress, so that slow
+	// runs do not pile up
+	running int32
+}
+
+// ManagerStats aggregates the statistics of the loggers of a Manager.
+// Total sums the counters of all loggers; its CurrentFile is empty and its
+// CurrentFileSize is the size of all current files together.
+type ManagerStats struct {
+	Total   Stats            `json:"total"`
+	Loggers map[string]Stats `json:"loggers"`
+}
+
+// NewManager creates a Manager. cronTime is the maintenance schedule,
+// in the format of WithCronTime, shared by all loggers. When it is empty
+// maintenance only runs when RunMaintenance is called.
+//
+// options are applied to every logger created by Add, before the options
+// given there. The schedule is evaluated in the time zone of the clock
+// they set with WithClock or WithLocation, the local time zone by
+// default, like the schedule of a single logger.
+func NewManager(cronTime string, options ...Option) (*Manager, error) {
+	m := &Manager{loggers: make(map[string]*RotateLogs), defaults: options}
+	if cronTime != common.IsNull {
+		m.cron = cron.NewWithLocation(clockOf(options).Now().Location())
+		if err := m.cron.AddFunc(cronTime, m.scheduledMaintenance); err != nil {
+			return nil, errors.Wrapf(err, "invalid cron time %q", cronTime)
+		}
+		m.cron.Start()
+	}
+	return m, nil
+}
+
+// Add creates a logger with the given options and registers it as name.
+// The file name defaults to name, options may override it. The logger is
+// started like Init would, but its maintenance is left to the manager's
+// scheduler, so WithCronTime must not be given.
+func (m *Manager) Add(name string, options ...Option) (*RotateLogs, error) {
+	all := append([]Option{WithFileName(name)}, m.defaults...)
+	rl, err := New(append(all, options...)...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create logger %q", name)
+	}
+	if rl.cronTime != common.IsNull {
+		rl.Close()
+		return nil, errors.Errorf("logger %q has its own cron time, maintenance is scheduled by the manager", name)
+	}
+
+	m.mutex.Lock()
+	if m.closed {
+		m.mutex.Unlock()
+		rl.Close()
+		return nil, errors.New("the manager is closed")
+	}
+	if _, ok := m.loggers[name]; ok {
+		m.mutex.Unlock()
+		rl.Close()
+		return nil, errors.Errorf("logger %q already exists", name)
+	}
+	m.loggers[name] = rl
+	m.jobs.Add(1)
+	m.mutex.Unlock()
+
+	go func() {
+		defer m.jobs.Done()
+		rl.runMaintenance()
+	}()
+	rl.start()
+	return rl, nil
+}
+
+// clockOf returns the clock set by options, Local if there is none
+func clockOf(options []Option) Clock {
+	var clock Clock = Local
+	for _, o := range options {
+		if o.Name() == optkeyClock {
+			clock = o.Value().(Clock)
+		}
+	}
+	return clock
+}
+
+// Get returns the logger registered as name, or nil if there is none.
+func (m *Manager) Get(name string) *RotateLogs {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.loggers[name]
+}
+
+// Names returns the names of the registered loggers, sorted.
+func (m *Manager) Names() []string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	names := make([]string, 0, len(m.loggers))
+	for name := range m.loggers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// each calls fn for every logger in name order, and returns the first
+// error, annotated with the logger's name. All loggers are visited even
+// if some of them fail.
+func (m *Manager) each(fn func(*RotateLogs) error) error {
+	var first error
+	for _, name := range m.Names() {
+		rl := m.Get(name)
+		if rl == nil {
+			continue
+		}
+		if err := fn(rl); err != nil && first == nil {
+			first = errors.Wrapf(err, "logger %q", name)
+		}
+	}
+	return first
+}
+
+// RotateAll forcefully rotates every logger, see Rotate.
+func (m *Manager) RotateAll() error {
+	return m.each((*RotateLogs).Rotate)
+}
+
+// RunMaintenance applies retention and compression to every logger, one
+// after another.
+func (m *Manager) RunMaintenance() error {
+	return m.each((*RotateLogs).RunMaintenance)
+}
+
+func (m *Manager) scheduledMaintenance() {
+	if !atomic.CompareAndSwapInt32(&m.running, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&m.running, 0)
+
+	// registered under the lock, so that CloseAll either sees the run or
+	// the run sees that the manager is closed
+	m.mutex.Lock()
+	if m.closed {
+		m.mutex.Unlock()
+		return
+	}
+	m.jobs.Add(1)
+	m.mutex.Unlock()
+	defer m.jobs.Done()
+
+	m.RunMaintenance()
+}
+
+// Stats returns the statistics of every logger and their sum.
+func (m *Manager) Stats() ManagerStats {
+	ms := ManagerStats{Loggers: make(map[string]Stats)}
+	for _, name := range m.Names() {
+		rl := m.Get(name)
+		if rl == nil {
+			continue
+		}
+		s := rl.Stats()
+		ms.Loggers[name] = s
+		ms.Total.add(s)
+	}
+	return ms
+}
+
+// CloseAll stops the scheduler, waits for the maintenance runs in
+// progress and closes every logger. The manager can not be used to add
+// loggers afterwards.
+func (m *Manager) CloseAll() error {
+	m.mutex.Lock()
+	m.closed = true
+	m.mutex.Unlock()
+
+	if m.cron != nil {
+		m.cron.Stop()
+	}
+	m.jobs.Wait()
+	return m.each((*RotateLogs).Close)
+}
+
+// add sums the counters of o into s and keeps the latest of the
+// timestamps.
+func (s *Stats) add(o Stats) {
+	s.BytesWritten += o.BytesWritten
+	s.Writes += o.Writes
+	s.WriteErrors += o.WriteErrors
+	s.CurrentFileSize += o.CurrentFileSize
+
+	if s.Rotations == nil {
+		s.Rotations = make(map[RotationReason]uint64)
+	}
+	for reason, n := range o.Rotations {
+		s.Rotations[reason] += n
+	}
+	s.RotationFailures += o.RotationFailures
+	s.Reopens += o.Reopens
+	s.ReopenFailures += o.ReopenFailures
+
+	s.Compressions += o.Compressions
+	s.CompressionFailures += o.CompressionFailures
+	s.CompressedBytesSaved += o.CompressedBytesSaved
+	s.CompressionDuration += o.CompressionDuration
+
+	s.Deletions += o.Deletions
+	s.VetoedDeletions += o.VetoedDeletions
+	s.DroppedEvents += o.DroppedEvents
+
+	s.MaintenanceRuns += o.MaintenanceRuns
+	s.MaintenanceDuration += o.MaintenanceDuration
+	if o.LastMaintenanceStart.After(s.LastMaintenanceStart) {
+		s.LastMaintenanceStart = o.LastMaintenanceStart
+		s.LastMaintenanceDuration = o.LastMaintenanceDuration
+	}
+	if o.LastErrorTime.After(s.LastErrorTime) {
+		s.LastError = o.LastError
+		s.LastErrorTime = o.LastErrorTime
+	}
+}
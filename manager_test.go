@@ -0,0 +1,194 @@
+package rotatelogs_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+	"github.com/jonboulle/clockwork"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager(t *testing.T) {
+	// newManager creates a manager with the loggers web and api, which
+	// have written "web\n" and "api-v1\n"
+	newManager := func(t *testing.T, dir string) (*rotatelogs.Manager, map[string]*rotatelogs.RotateLogs) {
+		m, err := rotatelogs.NewManager("",
+			rotatelogs.WithFilePath(dir+string(filepath.Separator)),
+			rotatelogs.WithClock(clockwork.NewFakeClockAt(testTime)),
+			rotatelogs.WithEventDelivery(rotatelogs.SyncDelivery),
+		)
+		if !assert.NoError(t, err, "NewManager should succeed") {
+			t.FailNow()
+		}
+		loggers := make(map[string]*rotatelogs.RotateLogs)
+		for name, content := range map[string]string{"web": "web\n", "api": "api-v1\n"} {
+			rl, err := m.Add(name)
+			if !assert.NoError(t, err, "adding %s should succeed", name) {
+				m.CloseAll()
+				t.FailNow()
+			}
+			rl.Write([]byte(content))
+			loggers[name] = rl
+		}
+		return m, loggers
+	}
+
+	t.Run("Defaults", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+
+		m, err := rotatelogs.NewManager("", rotatelogs.WithFilePath(dir+string(filepath.Separator)))
+		if !assert.NoError(t, err, "NewManager should succeed") {
+			return
+		}
+		defer m.CloseAll()
+		for _, name := range []string{"web", "api"} {
+			rl, err := m.Add(name)
+			if !assert.NoError(t, err, "adding %s should succeed", name) {
+				return
+			}
+			rl.Write([]byte(name + "\n"))
+		}
+		_, err = m.Add("web")
+		assert.Error(t, err, "adding a name twice should fail")
+		assert.Equal(t, []string{"api", "web"}, m.Names())
+
+		for _, name := range []string{"web", "api"} {
+			matches, err := filepath.Glob(filepath.Join(dir, name+"-*.log"))
+			if assert.NoError(t, err) {
+				assert.Len(t, matches, 1, "the defaults should place %s in the directory", name)
+			}
+		}
+	})
+
+	t.Run("Location", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+
+		// a zone whose hour differs from the local one, so that the
+		// schedule only fires if it is evaluated in that zone
+		_, offset := time.Now().Zone()
+		loc := time.FixedZone("manager-test", offset+12*60*60)
+		now := time.Now().In(loc)
+		if now.Minute() == 59 && now.Second() > 55 {
+			time.Sleep(5 * time.Second)
+			now = time.Now().In(loc)
+		}
+
+		m, err := rotatelogs.NewManager(fmt.Sprintf("* * %d * * *", now.Hour()), rotatelogs.WithLocation(loc))
+		if !assert.NoError(t, err, "NewManager should succeed") {
+			return
+		}
+		defer m.CloseAll()
+		var runs int32
+		_, err = m.Add("app",
+			rotatelogs.WithFilePath(dir+string(filepath.Separator)),
+			rotatelogs.WithHandler(rotatelogs.HandlerFunc(func(e rotatelogs.Event) {
+				if e.Type() == rotatelogs.MaintenanceCompletedEventType {
+					atomic.AddInt32(&runs, 1)
+				}
+			})))
+		if !assert.NoError(t, err, "Add should succeed") {
+			return
+		}
+
+		// the first run is the one of Add
+		for deadline := time.Now().Add(5 * time.Second); atomic.LoadInt32(&runs) < 2 && time.Now().Before(deadline); {
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.True(t, atomic.LoadInt32(&runs) >= 2, "the schedule should fire in the location of the clock")
+	})
+
+	t.Run("CloseAllWaitsForMaintenance", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		writeLogFile(t, dir, testTime.AddDate(0, 0, -10), "old\n", testTime.AddDate(0, 0, -10))
+
+		m, err := rotatelogs.NewManager("* * * * * *")
+		if !assert.NoError(t, err, "NewManager should succeed") {
+			return
+		}
+		var inside int32
+		entered := make(chan struct{}, 1)
+		_, err = m.Add("app",
+			rotatelogs.WithFilePath(dir+string(filepath.Separator)),
+			rotatelogs.WithMaxAge(1),
+			rotatelogs.WithDeleteHook(rotatelogs.DeleteHookFunc(func(path string) error {
+				atomic.AddInt32(&inside, 1)
+				defer atomic.AddInt32(&inside, -1)
+				select {
+				case entered <- struct{}{}:
+				default:
+				}
+				time.Sleep(200 * time.Millisecond)
+				return errors.New("keep")
+			})))
+		if !assert.NoError(t, err, "Add should succeed") {
+			m.CloseAll()
+			return
+		}
+
+		select {
+		case <-entered:
+		case <-time.After(5 * time.Second):
+			m.CloseAll()
+			assert.Fail(t, "maintenance should consult the delete hook")
+			return
+		}
+		assert.NoError(t, m.CloseAll(), "CloseAll should succeed")
+		assert.Equal(t, int32(0), atomic.LoadInt32(&inside), "CloseAll should wait for the maintenance in progress")
+	})
+
+	t.Run("RotateAll", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		m, loggers := newManager(t, dir)
+		defer m.CloseAll()
+
+		assert.NoError(t, m.RotateAll(), "RotateAll should succeed")
+		for name, rl := range loggers {
+			assert.Equal(t, filepath.Join(dir, name+"-2021-11-14.log.1.log"), rl.CurrentFileName(), "%s should be rotated", name)
+			assert.EqualValues(t, 1, rl.Stats().Rotations[rotatelogs.RotationReasonManual])
+		}
+	})
+
+	t.Run("Get", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		m, loggers := newManager(t, dir)
+		defer m.CloseAll()
+
+		for name, rl := range loggers {
+			assert.True(t, rl == m.Get(name), "Get should return the logger added as %s", name)
+		}
+		assert.Nil(t, m.Get("unknown"), "Get should return nil for an unknown name")
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		m, loggers := newManager(t, dir)
+		defer m.CloseAll()
+		loggers["web"].Rotate()
+		m.RunMaintenance()
+
+		ms := m.Stats()
+		if !assert.Len(t, ms.Loggers, 2) {
+			return
+		}
+		assert.EqualValues(t, len("web\n"), ms.Loggers["web"].BytesWritten)
+		assert.EqualValues(t, len("api-v1\n"), ms.Loggers["api"].BytesWritten)
+		assert.EqualValues(t, len("web\n")+len("api-v1\n"), ms.Total.BytesWritten, "the total should sum the loggers")
+		assert.EqualValues(t, 2, ms.Total.Writes)
+		assert.EqualValues(t, 2, ms.Total.Rotations[rotatelogs.RotationReasonStartup])
+		assert.EqualValues(t, 1, ms.Total.Rotations[rotatelogs.RotationReasonManual])
+		assert.EqualValues(t, ms.Loggers["web"].MaintenanceRuns+ms.Loggers["api"].MaintenanceRuns, ms.Total.MaintenanceRuns)
+		assert.True(t, ms.Total.MaintenanceRuns >= 2, "the run of the manager should be counted")
+	})
+}
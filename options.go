@@ -17,6 +17,16 @@ const (
 	optkeyFileName      = "file-name"
 	optkeyCompressFile  = "compress-file"
 	optkeyCronTime      = "cron-time"
+
+	optkeyTrashGracePeriod = "trash-grace-period"
+	optkeyDeleteHook       = "delete-hook"
+	optkeyAgeSource        = "age-source"
+	optkeyEventDelivery    = "event-delivery"
+	optkeyPostRotate       = "post-rotate"
+	optkeyShipper          = "shipper"
+	optkeyHeader           = "header"
+	optkeyFooter           = "footer"
+	optkeyFooterOnClose    = "footer-on-close"
 )
 
 // WithClock creates a new Option that sets a clock
@@ -57,6 +67,20 @@ func WithMaxAge(day int) Option {
 	return option.New(optkeyMaxAge, day)
 }
 
+// WithMaxAgeDuration creates a new Option that sets the
+// max age of a log file with sub-day precision. It
+// replaces any value set by WithMaxAge.
+func WithMaxAgeDuration(d time.Duration) Option {
+	return option.New(optkeyMaxAge, d)
+}
+
+// WithAgeSource creates a new Option that selects whether
+// the age of a log file is taken from the date in its name
+// (the default) or from its modification time.
+func WithAgeSource(src AgeSource) Option {
+	return option.New(optkeyAgeSource, src)
+}
+
 // WithRotationTime creates a new Option that sets the
 // time between rotation.
 func WithRotationTime(day int) Option {
@@ -69,6 +93,13 @@ func WithRotationSize(sizeMB int) Option {
 	return option.New(optkeyRotationSize, sizeMB)
 }
 
+// WithRotationSizeBytes creates a new Option that sets the
+// log file size between rotation in bytes. It replaces any
+// value set by WithRotationSize.
+func WithRotationSizeBytes(size int64) Option {
+	return option.New(optkeyRotationSize, size)
+}
+
 // WithRotationCount creates a new Option that sets the
 // number of files should be kept before it gets
 // purged from the file system.
@@ -78,11 +109,21 @@ func WithRotationCount(num uint) Option {
 
 // WithHandler creates a new Option that specifies the
 // Handler object that gets invoked when an event occurs.
-// Currently `FileRotated` event is supported
+// Events cover rotation, file creation, compression,
+// deletion, failures and completed maintenance runs.
 func WithHandler(h Handler) Option {
 	return option.New(optkeyHandler, h)
 }
 
+// WithEventDelivery creates a new Option that selects
+// whether events are delivered to the Handler
+// asynchronously (the default) or synchronously. Either
+// way events arrive in the order they were produced and
+// a panicking Handler does not crash the process.
+func WithEventDelivery(d EventDelivery) Option {
+	return option.New(optkeyEventDelivery, d)
+}
+
 func WithFilePath(filePath string) Option {
 	return option.New(optkeyFilePath, filePath)
 }
@@ -98,3 +139,57 @@ func WithCompressFile(needCompress bool) Option {
 func WithCronTime(cronTime string) Option {
 	return option.New(optkeyCronTime, cronTime)
 }
+
+// WithTrashGracePeriod creates a new Option that makes retention move
+// expired files into a ".trash" subdirectory of the log directory
+// instead of removing them. Quarantined files are removed for good once
+// they have been in the trash for longer than grace, and can be listed
+// and restored until then via Quarantined and Restore.
+func WithTrashGracePeriod(grace time.Duration) Option {
+	return option.New(optkeyTrashGracePeriod, grace)
+}
+
+// WithDeleteHook creates a new Option that specifies the DeleteHook
+// consulted before retention deletes a file. Vetoed files are kept and
+// evaluated again on the next maintenance run.
+func WithDeleteHook(h DeleteHook) Option {
+	return option.New(optkeyDeleteHook, h)
+}
+
+// WithPostRotateCommand creates a new Option that runs an
+// external command after each rotation and/or compression.
+// The outcome of every run is reported through a
+// CommandCompletedEvent.
+func WithPostRotateCommand(cmd PostRotateCommand) Option {
+	return option.New(optkeyPostRotate, cmd)
+}
+
+// WithShipper creates a new Option that ships every
+// rotated (or, with compression, compressed) file to
+// an ObjectStore. Pending uploads are journaled in the
+// log directory, resume after a restart, and hold back
+// retention until they succeed or are given up on.
+func WithShipper(cfg ShipperConfig) Option {
+	return option.New(optkeyShipper, cfg)
+}
+
+// WithHeader creates a new Option that writes a header
+// whenever a new file is created, e.g. the column names
+// of a CSV log.
+func WithHeader(fn HeaderFunc) Option {
+	return option.New(optkeyHeader, fn)
+}
+
+// WithFooter creates a new Option that writes a trailer
+// to each file before rotating away from it or closing it.
+func WithFooter(fn FooterFunc) Option {
+	return option.New(optkeyFooter, fn)
+}
+
+// WithFooterOnClose sets whether Close writes the footer
+// to the current file (default true). Disable it when the
+// file is appended to again after a restart and a footer
+// must only appear at its very end.
+func WithFooterOnClose(enabled bool) Option {
+	return option.New(optkeyFooterOnClose, enabled)
+}
@@ -0,0 +1,162 @@
+package rotatelogs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chriszhangmq/file-rotatelogs/internal/common"
+	"github.com/chriszhangmq/file-rotatelogs/internal/fileutil"
+	"github.com/pkg/errors"
+)
+
+// pinSet keeps the base names of the pinned files. It is persisted as a
+// small JSON sidecar file in the log directory so pins survive restarts.
+// The sidecar is read again whenever it changes, so that pins edited by
+// hand or by another process take effect without a restart.
+type pinSet struct {
+	mutex  sync.Mutex
+	path   string
+	loaded bool
+	names  map[string]struct{}
+
+	// the sidecar as it was last read, zero if it did not exist
+	modTime time.Time
+	size    int64
+}
+
+type pinFile struct {
+	Files []string `json:"files"`
+}
+
+// pinKey normalises a file name so that a log file and its compressed
+// counterpart share the same pin.
+func pinKey(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), common.CompressSuffix)
+}
+
+// must be locked during this operation
+func (p *pinSet) loadNolock() error {
+	var modTime time.Time
+	var size int64
+	fi, err := os.Stat(p.path)
+	if err == nil {
+		modTime, size = fi.ModTime(), fi.Size()
+	} else if !os.IsNotExist(err) {
+		return errors.Wrapf(err, `failed to read pin file %s`, p.path)
+	}
+	if p.loaded && modTime.Equal(p.modTime) && size == p.size {
+		return nil
+	}
+
+	names := make(map[string]struct{})
+	buf, err := ioutil.ReadFile(p.path)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, `failed to read pin file %s`, p.path)
+	}
+	if err == nil {
+		var pf pinFile
+		if err := json.Unmarshal(buf, &pf); err != nil {
+			return errors.Wrapf(err, `failed to parse pin file %s`, p.path)
+		}
+		for _, name := range pf.Files {
+			names[name] = struct{}{}
+		}
+	}
+	p.names = names
+	p.modTime, p.size = modTime, size
+	p.loaded = true
+	return nil
+}
+
+// must be locked during this operation
+func (p *pinSet) saveNolock() error {
+	pf := pinFile{Files: make([]string, 0, len(p.names))}
+	for name := range p.names {
+		pf.Files = append(pf.Files, name)
+	}
+	sort.Strings(pf.Files)
+	buf, err := json.MarshalIndent(pf, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := fileutil.WriteFileAtomic(p.path, buf, 0644); err != nil {
+		return err
+	}
+	// the sidecar now holds the names in memory
+	if fi, err := os.Stat(p.path); err == nil {
+		p.modTime, p.size = fi.ModTime(), fi.Size()
+	}
+	return nil
+}
+
+func (p *pinSet) set(key string, pinned bool) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if err := p.loadNolock(); err != nil {
+		return err
+	}
+	if _, ok := p.names[key]; ok == pinned {
+		return nil
+	}
+	if pinned {
+		p.names[key] = struct{}{}
+	} else {
+		delete(p.names, key)
+	}
+	return p.saveNolock()
+}
+
+func (p *pinSet) has(path string) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if err := p.loadNolock(); err != nil {
+		// when in doubt, keep the file
+		return true
+	}
+	_, ok := p.names[pinKey(path)]
+	return ok
+}
+
+func (p *pinSet) list() ([]string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if err := p.loadNolock(); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(p.names))
+	for name := range p.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Pin exempts a log file from every retention policy: it is neither
+// deleted, quarantined nor compressed until Unpin is called. path may be
+// the full path or the base name of a file managed by this object; a file
+// and its compressed counterpart are pinned together.
+func (rl *RotateLogs) Pin(path string) error {
+	if !rl.matcher.Match(path) {
+		return errors.Errorf(`%s is not managed by this logger`, path)
+	}
+	return rl.pins.set(pinKey(path), true)
+}
+
+// Unpin makes a file pinned by Pin subject to retention again.
+func (rl *RotateLogs) Unpin(path string) error {
+	return rl.pins.set(pinKey(path), false)
+}
+
+// Pinned returns the base names of the pinned files.
+func (rl *RotateLogs) Pinned() ([]string, error) {
+	return rl.pins.list()
+}
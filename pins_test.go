@@ -0,0 +1,143 @@
+package rotatelogs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPins(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	old := testTime.AddDate(0, 0, -3)
+	pinned := writeLogFile(t, dir, old, "pinned", old)
+	expired := writeLogFile(t, dir, old.AddDate(0, 0, -1), "expired", old)
+	sidecar := filepath.Join(dir, ".app.pins")
+
+	options := []rotatelogs.Option{
+		rotatelogs.WithClock(clockwork.NewFakeClockAt(testTime)),
+		rotatelogs.WithMaxAgeDuration(24 * time.Hour),
+		rotatelogs.WithCompressFile(true),
+	}
+
+	t.Run("Pins are saved to the sidecar", func(t *testing.T) {
+		rl := newLogger(t, dir, options...)
+		defer rl.Close()
+
+		assert.Error(t, rl.Pin("other-2021-11-11.log"), "files of other loggers cannot be pinned")
+		if !assert.NoError(t, rl.Pin(pinned), "pinning should succeed") {
+			return
+		}
+		// the compressed counterpart shares the pin
+		assert.NoError(t, rl.Pin(filepath.Base(pinned)+".gz"), "pinning twice should succeed")
+
+		content, err := ioutil.ReadFile(sidecar)
+		if !assert.NoError(t, err, "the sidecar should have been written") {
+			return
+		}
+		assert.JSONEq(t, `{"files":["app-2021-11-11.log"]}`, string(content))
+	})
+
+	t.Run("Pins are loaded from the sidecar", func(t *testing.T) {
+		rl := newLogger(t, dir, options...)
+		defer rl.Close()
+
+		names, err := rl.Pinned()
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []string{filepath.Base(pinned)}, names)
+
+		if !assert.NoError(t, rl.RunMaintenance(), "maintenance should succeed") {
+			return
+		}
+		assert.FileExists(t, pinned, "pinned files should be neither deleted nor compressed")
+		assertNoFile(t, pinned+".gz")
+		assertNoFile(t, expired, "unpinned files should be deleted")
+	})
+
+	t.Run("Stale pins are kept until unpinned", func(t *testing.T) {
+		if !assert.NoError(t, os.Remove(pinned)) {
+			return
+		}
+		rl := newLogger(t, dir, options...)
+		defer rl.Close()
+
+		if !assert.NoError(t, rl.RunMaintenance(), "maintenance should succeed with a stale pin") {
+			return
+		}
+		names, err := rl.Pinned()
+		if assert.NoError(t, err) {
+			assert.Equal(t, []string{filepath.Base(pinned)}, names, "a pin outlives its file")
+		}
+
+		if !assert.NoError(t, rl.Unpin(pinned), "unpinning should succeed") {
+			return
+		}
+		names, err = rl.Pinned()
+		if assert.NoError(t, err) {
+			assert.Len(t, names, 0)
+		}
+		content, err := ioutil.ReadFile(sidecar)
+		if assert.NoError(t, err) {
+			assert.JSONEq(t, `{"files":[]}`, string(content))
+		}
+	})
+
+	t.Run("External changes are picked up", func(t *testing.T) {
+		writeLogFile(t, dir, old, "pinned", old)
+		rl := newLogger(t, dir, options...)
+		defer rl.Close()
+		names, err := rl.Pinned()
+		if !assert.NoError(t, err) || !assert.Len(t, names, 0) {
+			return
+		}
+
+		// the sidecar is edited by another process while rl runs; the
+		// times are moved on so that coarse timestamps see the change
+		edit := func(content string, mtime time.Time) bool {
+			return assert.NoError(t, ioutil.WriteFile(sidecar, []byte(content), 0644)) &&
+				assert.NoError(t, os.Chtimes(sidecar, mtime, mtime))
+		}
+		if !edit(`{"files":["app-2021-11-11.log"]}`, time.Now().Add(time.Minute)) {
+			return
+		}
+		names, err = rl.Pinned()
+		if assert.NoError(t, err) {
+			assert.Equal(t, []string{filepath.Base(pinned)}, names, "a pin added to the sidecar should be read")
+		}
+		if !assert.NoError(t, rl.RunMaintenance(), "maintenance should succeed") {
+			return
+		}
+		assert.FileExists(t, pinned, "a pin added to the sidecar should keep the file")
+
+		if !edit(`{"files":[]}`, time.Now().Add(2*time.Minute)) {
+			return
+		}
+		if !assert.NoError(t, rl.RunMaintenance(), "maintenance should succeed") {
+			return
+		}
+		assertNoFile(t, pinned, "a pin removed from the sidecar should no longer keep the file")
+	})
+
+	t.Run("A corrupt sidecar keeps every file", func(t *testing.T) {
+		if !assert.NoError(t, ioutil.WriteFile(sidecar, []byte("{"), 0644)) {
+			return
+		}
+		writeLogFile(t, dir, old, "kept", old)
+		rl := newLogger(t, dir, options...)
+		defer rl.Close()
+
+		_, err := rl.Pinned()
+		assert.Error(t, err, "a corrupt sidecar should be reported")
+		rl.RunMaintenance()
+		assert.FileExists(t, pinned, "files should be kept when pins cannot be read")
+	})
+}
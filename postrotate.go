@@ -0,0 +1,140 @@
+package rotatelogs
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// PostRotateCommand describes an external command that is run after a
+// rotation and/or a compression, in the spirit of logrotate's postrotate
+// scripts. The affected file is appended to Args and also exported in the
+// ROTATELOGS_FILE environment variable; ROTATELOGS_EVENT is set to
+// "rotate" or "compress".
+type PostRotateCommand struct {
+	Path          string        // executable to run
+	Args          []string      // arguments placed before the file path
+	Timeout       time.Duration // kill the command after this long (0: no limit)
+	MaxConcurrent int           // commands allowed to run at once (default 1)
+	OnRotate      bool          // run with the previous file after each rotation
+	OnCompress    bool          // run with the compressed file after each compression
+}
+
+// maxCommandOutput bounds how much of the standard output and error of a
+// command is kept for its CommandCompletedEvent
+const maxCommandOutput = 64 * 1024
+
+// tailBuffer keeps the last max bytes written to it
+type tailBuffer struct {
+	max int
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	if len(p) >= b.max {
+		b.buf = append(b.buf[:0], p[len(p)-b.max:]...)
+		return len(p), nil
+	}
+	if drop := len(b.buf) + len(p) - b.max; drop > 0 {
+		b.buf = b.buf[:copy(b.buf, b.buf[drop:])]
+	}
+	b.buf = append(b.buf, p...)
+	return len(p), nil
+}
+
+func (b *tailBuffer) Bytes() []byte {
+	return b.buf
+}
+
+// commandRunner runs a PostRotateCommand with bounded concurrency
+type commandRunner struct {
+	cmd  PostRotateCommand
+	sem  chan struct{}
+	wg   sync.WaitGroup
+	emit func(Event)
+}
+
+func newCommandRunner(cmd PostRotateCommand, emit func(Event)) *commandRunner {
+	if cmd.MaxConcurrent <= 0 {
+		cmd.MaxConcurrent = 1
+	}
+	return &commandRunner{
+		cmd:  cmd,
+		sem:  make(chan struct{}, cmd.MaxConcurrent),
+		emit: emit,
+	}
+}
+
+// trigger runs the command for file in the background if the command
+// is interested in events of type t
+func (r *commandRunner) trigger(t EventType, file string) {
+	if r == nil || file == "" {
+		return
+	}
+	var name string
+	switch {
+	case t == FileRotatedEventType && r.cmd.OnRotate:
+		name = "rotate"
+	case t == FileCompressedEventType && r.cmd.OnCompress:
+		name = "compress"
+	default:
+		return
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.sem <- struct{}{}
+		defer func() { <-r.sem }()
+		r.emit(r.run(t, name, file))
+	}()
+}
+
+func (r *commandRunner) run(t EventType, name string, file string) *CommandCompletedEvent {
+	ctx := context.Background()
+	if r.cmd.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.cmd.Timeout)
+		defer cancel()
+	}
+
+	args := append(append([]string{}, r.cmd.Args...), file)
+	c := exec.CommandContext(ctx, r.cmd.Path, args...)
+	c.Env = append(os.Environ(), "ROTATELOGS_FILE="+file, "ROTATELOGS_EVENT="+name)
+	stdout := &tailBuffer{max: maxCommandOutput}
+	stderr := &tailBuffer{max: maxCommandOutput}
+	c.Stdout = stdout
+	c.Stderr = stderr
+	setWaitDelay(c)
+
+	start := time.Now()
+	err := c.Run()
+	ev := &CommandCompletedEvent{
+		command:  r.cmd.Path,
+		file:     file,
+		trigger:  t,
+		stdout:   stdout.Bytes(),
+		stderr:   stderr.Bytes(),
+		exitCode: -1,
+		err:      err,
+		duration: time.Since(start),
+		time:     time.Now(),
+	}
+	if c.ProcessState != nil {
+		ev.exitCode = c.ProcessState.ExitCode()
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		ev.err = ctx.Err()
+	}
+	return ev
+}
+
+// wait blocks until every running command has completed
+func (r *commandRunner) wait() {
+	if r == nil {
+		return
+	}
+	r.wg.Wait()
+}
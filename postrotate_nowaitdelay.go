@@ -0,0 +1,11 @@
+//go:build !go1.20
+// +build !go1.20
+
+package rotatelogs
+
+import "os/exec"
+
+// setWaitDelay does nothing before Go 1.20, which lacks exec.Cmd.WaitDelay:
+// a command leaving behind a process that holds its output pipes keeps
+// its CommandCompletedEvent from being emitted until that process exits.
+func setWaitDelay(c *exec.Cmd) {}
@@ -0,0 +1,91 @@
+package rotatelogs_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+// runPostRotate rotates once with cmd configured and returns the
+// CommandCompletedEvent of the run, if any.
+func runPostRotate(t *testing.T, cmd rotatelogs.PostRotateCommand) (*rotatelogs.CommandCompletedEvent, string) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	cmd.OnRotate = true
+	rl := newLogger(t, dir,
+		rotatelogs.WithClock(clockwork.NewFakeClockAt(testTime)),
+		rotatelogs.WithPostRotateCommand(cmd),
+	)
+	ch, _ := rl.Subscribe(64)
+	rl.Write([]byte("Hello, World\n"))
+	previous := rl.CurrentFileName()
+	if !assert.NoError(t, rl.Rotate(), "rl.Rotate should succeed") {
+		return nil, ""
+	}
+	// Close waits for the running commands
+	assert.NoError(t, rl.Close())
+
+	var completed *rotatelogs.CommandCompletedEvent
+	for e := range ch {
+		if ev, ok := e.(*rotatelogs.CommandCompletedEvent); ok {
+			assert.Nil(t, completed, "the command should run once")
+			completed = ev
+		}
+	}
+	assert.NotNil(t, completed, "the command should have completed")
+	return completed, previous
+}
+
+func TestPostRotateCommand(t *testing.T) {
+	t.Run("Arguments and environment", func(t *testing.T) {
+		ev, previous := runPostRotate(t, rotatelogs.PostRotateCommand{
+			Path: "sh",
+			Args: []string{"-c", `echo "$ROTATELOGS_EVENT $ROTATELOGS_FILE $0"; echo oops >&2; exit 3`},
+		})
+		if ev == nil {
+			return
+		}
+		assert.Equal(t, "sh", ev.Command())
+		assert.Equal(t, previous, ev.File())
+		assert.Equal(t, rotatelogs.FileRotatedEventType, ev.Trigger())
+		assert.Equal(t, "rotate "+previous+" "+previous+"\n", string(ev.Stdout()))
+		assert.Equal(t, "oops\n", string(ev.Stderr()))
+		assert.Equal(t, 3, ev.ExitCode())
+		assert.Error(t, ev.Err())
+	})
+
+	t.Run("Output is capped", func(t *testing.T) {
+		ev, _ := runPostRotate(t, rotatelogs.PostRotateCommand{
+			Path: "sh",
+			Args: []string{"-c", `i=0; while [ $i -lt 2000 ]; do echo 0123456789012345678901234567890123456789012345678901234567890123456789; i=$((i+1)); done; printf end`},
+		})
+		if ev == nil {
+			return
+		}
+		assert.NoError(t, ev.Err())
+		assert.Len(t, ev.Stdout(), 64*1024, "only the last 64 KiB should be kept")
+		assert.True(t, strings.HasSuffix(string(ev.Stdout()), "789\nend"), "the end of the output should be kept")
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		start := time.Now()
+		ev, _ := runPostRotate(t, rotatelogs.PostRotateCommand{
+			Path:    "sh",
+			Args:    []string{"-c", "sleep 10"},
+			Timeout: 100 * time.Millisecond,
+		})
+		if ev == nil {
+			return
+		}
+		assert.Equal(t, context.DeadlineExceeded, ev.Err())
+		assert.Equal(t, -1, ev.ExitCode(), "a killed command has no exit status")
+		assert.True(t, time.Since(start) < 5*time.Second, "the command should have been killed")
+	})
+}
@@ -0,0 +1,15 @@
+//go:build go1.20
+// +build go1.20
+
+package rotatelogs
+
+import (
+	"os/exec"
+	"time"
+)
+
+// setWaitDelay keeps Run from waiting forever for grandchildren that
+// still hold the output pipes after the command exited.
+func setWaitDelay(c *exec.Cmd) {
+	c.WaitDelay = time.Second
+}
@@ -0,0 +1,216 @@
+// Package promexport renders the runtime statistics of RotateLogs objects
+// in the Prometheus text exposition format, either over HTTP or as a file
+// for node_exporter's textfile collector, without depending on the
+// Prometheus client library.
+package promexport
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+	"github.com/pkg/errors"
+)
+
+// ContentType is the media type of the exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+const defaultNamespace = "rotatelogs"
+
+var reasons = []rotatelogs.RotationReason{
+	rotatelogs.RotationReasonStartup,
+	rotatelogs.RotationReasonTime,
+	rotatelogs.RotationReasonSize,
+	rotatelogs.RotationReasonManual,
+	rotatelogs.RotationReasonFileMissing,
+}
+
+// Exporter renders the statistics of a set of named RotateLogs objects.
+// Every metric carries a "logger" label with the registered name.
+type Exporter struct {
+	namespace string
+	mutex     sync.RWMutex
+	loggers   map[string]*rotatelogs.RotateLogs
+}
+
+// New creates an Exporter whose metric names start with namespace
+// ("rotatelogs" if empty).
+func New(namespace string) *Exporter {
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	return &Exporter{
+		namespace: namespace,
+		loggers:   make(map[string]*rotatelogs.RotateLogs),
+	}
+}
+
+// Register adds rl under name, replacing any logger with the same name.
+func (e *Exporter) Register(name string, rl *rotatelogs.RotateLogs) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.loggers[name] = rl
+}
+
+// Unregister removes the logger registered under name.
+func (e *Exporter) Unregister(name string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	delete(e.loggers, name)
+}
+
+// family describes a metric. value returns one sample per label set; the
+// labels are appended to the "logger" label.
+type family struct {
+	name  string
+	kind  string
+	help  string
+	value func(s rotatelogs.Stats) []sample
+}
+
+type sample struct {
+	labels string
+	value  float64
+}
+
+func single(f func(s rotatelogs.Stats) float64) func(rotatelogs.Stats) []sample {
+	return func(s rotatelogs.Stats) []sample {
+		return []sample{{value: f(s)}}
+	}
+}
+
+var families = []family{
+	{"bytes_written_total", "counter", "Bytes written to log files.", single(func(s rotatelogs.Stats) float64 { return float64(s.BytesWritten) })},
+	{"writes_total", "counter", "Write calls.", single(func(s rotatelogs.Stats) float64 { return float64(s.Writes) })},
+	{"write_errors_total", "counter", "Write calls that failed.", single(func(s rotatelogs.Stats) float64 { return float64(s.WriteErrors) })},
+	{"current_file_size_bytes", "gauge", "Size of the file currently written to.", single(func(s rotatelogs.Stats) float64 { return float64(s.CurrentFileSize) })},
+	{"rotations_total", "counter", "File rotations by reason.", func(s rotatelogs.Stats) []sample {
+		samples := make([]sample, 0, len(reasons))
+		for _, reason := range reasons {
+			samples = append(samples, sample{labels: fmt.Sprintf(`,reason="%s"`, reason), value: float64(s.Rotations[reason])})
+		}
+		return samples
+	}},
+	{"rotation_failures_total", "counter", "Rotations that failed.", single(func(s rotatelogs.Stats) float64 { return float64(s.RotationFailures) })},
+	{"reopens_total", "counter", "Current files reopened after being replaced or truncated.", single(func(s rotatelogs.Stats) float64 { return float64(s.Reopens) })},
+	{"reopen_failures_total", "counter", "Reopens of the current file that failed.", single(func(s rotatelogs.Stats) float64 { return float64(s.ReopenFailures) })},
+	{"compressions_total", "counter", "Files compressed.", single(func(s rotatelogs.Stats) float64 { return float64(s.Compressions) })},
+	{"compression_failures_total", "counter", "Compressions that failed.", single(func(s rotatelogs.Stats) float64 { return float64(s.CompressionFailures) })},
+	{"compressed_bytes_saved_total", "counter", "Bytes saved by compression.", single(func(s rotatelogs.Stats) float64 { return float64(s.CompressedBytesSaved) })},
+	{"compression_seconds_total", "counter", "Time spent compressing.", single(func(s rotatelogs.Stats) float64 { return s.CompressionDuration.Seconds() })},
+	{"deletions_total", "counter", "Files deleted or quarantined by retention.", single(func(s rotatelogs.Stats) float64 { return float64(s.Deletions) })},
+	{"vetoed_deletions_total", "counter", "Deletions vetoed by the delete hook.", single(func(s rotatelogs.Stats) float64 { return float64(s.VetoedDeletions) })},
+	{"dropped_events_total", "counter", "Events dropped because a subscriber was full.", single(func(s rotatelogs.Stats) float64 { return float64(s.DroppedEvents) })},
+	{"maintenance_runs_total", "counter", "Completed maintenance runs.", single(func(s rotatelogs.Stats) float64 { return float64(s.MaintenanceRuns) })},
+	{"maintenance_seconds_total", "counter", "Time spent in maintenance runs.", single(func(s rotatelogs.Stats) float64 { return s.MaintenanceDuration.Seconds() })},
+	{"last_maintenance_duration_seconds", "gauge", "Duration of the latest maintenance run.", single(func(s rotatelogs.Stats) float64 { return s.LastMaintenanceDuration.Seconds() })},
+	{"last_maintenance_timestamp_seconds", "gauge", "Start of the latest maintenance run.", single(func(s rotatelogs.Stats) float64 { return timestamp(s.LastMaintenanceStart) })},
+	{"last_error_timestamp_seconds", "gauge", "Time of the latest error.", single(func(s rotatelogs.Stats) float64 { return timestamp(s.LastErrorTime) })},
+}
+
+// WriteTo writes the current value of every metric to w.
+func (e *Exporter) WriteTo(w io.Writer) (int64, error) {
+	e.mutex.RLock()
+	names := make([]string, 0, len(e.loggers))
+	stats := make(map[string]rotatelogs.Stats, len(e.loggers))
+	for name, rl := range e.loggers {
+		names = append(names, name)
+		stats[name] = rl.Stats()
+	}
+	e.mutex.RUnlock()
+	sort.Strings(names)
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for _, f := range families {
+		name := e.namespace + "_" + f.name
+		fmt.Fprintf(cw, "# HELP %s %s\n", name, f.help)
+		fmt.Fprintf(cw, "# TYPE %s %s\n", name, f.kind)
+		for _, logger := range names {
+			for _, s := range f.value(stats[logger]) {
+				fmt.Fprintf(cw, "%s{logger=\"%s\"%s} %g\n", name, escape(logger), s.labels, s.value)
+			}
+		}
+	}
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// ServeHTTP serves the metrics, so the Exporter can be mounted as a
+// scrape endpoint.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	var buf bytes.Buffer
+	if _, err := e.WriteTo(&buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.Write(buf.Bytes())
+}
+
+// WriteTextfile atomically replaces path with the current metrics, for
+// use with node_exporter's textfile collector. path should end in
+// ".prom".
+func (e *Exporter) WriteTextfile(path string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return errors.Wrapf(err, `failed to create temporary file for %s`, path)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := e.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, `failed to write %s`, tmp.Name())
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, `failed to chmod %s`, tmp.Name())
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrapf(err, `failed to close %s`, tmp.Name())
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errors.Wrapf(err, `failed to rename %s`, tmp.Name())
+	}
+	return nil
+}
+
+func timestamp(t time.Time) float64 {
+	if t.IsZero() {
+		return 0
+	}
+	return float64(t.UnixNano()) / 1e9
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
@@ -0,0 +1,92 @@
+package promexport_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+	"github.com/chriszhangmq/file-rotatelogs/promexport"
+	"github.com/stretchr/testify/assert"
+)
+
+func newLogger(t *testing.T, dir, name string) *rotatelogs.RotateLogs {
+	rl, err := rotatelogs.New(
+		rotatelogs.WithFilePath(dir+"/"),
+		rotatelogs.WithFileName(name),
+		rotatelogs.WithMaxAge(7),
+	)
+	if !assert.NoError(t, err, `rotatelogs.New should succeed`) {
+		t.FailNow()
+	}
+	return rl
+}
+
+func TestExporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-rotatelogs-promexport")
+	if !assert.NoError(t, err, "creating temporary directory should succeed") {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	rl := newLogger(t, dir, "app")
+	defer rl.Close()
+	rl.Write([]byte("Hello, World"))
+
+	e := promexport.New("")
+	e.Register(`we"ird`, rl)
+
+	var buf bytes.Buffer
+	if _, err := e.WriteTo(&buf); !assert.NoError(t, err, "WriteTo should succeed") {
+		return
+	}
+	out := buf.String()
+	for _, line := range []string{
+		"# TYPE rotatelogs_bytes_written_total counter",
+		`rotatelogs_bytes_written_total{logger="we\"ird"} 12`,
+		`rotatelogs_writes_total{logger="we\"ird"} 1`,
+		`rotatelogs_current_file_size_bytes{logger="we\"ird"} 12`,
+		`rotatelogs_rotations_total{logger="we\"ird",reason="startup"} 1`,
+		`rotatelogs_rotations_total{logger="we\"ird",reason="size"} 0`,
+	} {
+		assert.Contains(t, out, line+"\n")
+	}
+
+	e.Unregister(`we"ird`)
+	buf.Reset()
+	e.WriteTo(&buf)
+	assert.NotContains(t, buf.String(), "logger=")
+}
+
+func TestExporterHTTPAndTextfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-rotatelogs-promexport")
+	if !assert.NoError(t, err, "creating temporary directory should succeed") {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	rl := newLogger(t, dir, "app")
+	defer rl.Close()
+
+	e := promexport.New("myapp_logs")
+	e.Register("app", rl)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, promexport.ContentType, rec.Header().Get("Content-Type"))
+	assert.True(t, strings.Contains(rec.Body.String(), `myapp_logs_writes_total{logger="app"} 0`), "body should contain the writes counter")
+
+	path := filepath.Join(dir, "rotatelogs.prom")
+	if !assert.NoError(t, e.WriteTextfile(path), "WriteTextfile should succeed") {
+		return
+	}
+	content, err := ioutil.ReadFile(path)
+	if !assert.NoError(t, err, "reading the textfile should succeed") {
+		return
+	}
+	assert.Equal(t, rec.Body.String(), string(content))
+}
@@ -0,0 +1,142 @@
+package rotatelogs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+// newReopenLogger creates a logger that has written "first\n" to its
+// current file, and a subscription to its events.
+func newReopenLogger(t *testing.T, dir string) (*rotatelogs.RotateLogs, <-chan rotatelogs.Event, func()) {
+	rl := newLogger(t, dir, rotatelogs.WithClock(clockwork.NewFakeClockAt(testTime)))
+	if _, err := rl.Write([]byte("first\n")); !assert.NoError(t, err, "Write should succeed") {
+		rl.Close()
+		t.FailNow()
+	}
+	events, cancel := rl.Subscribe(16)
+	return rl, events, cancel
+}
+
+func assertContent(t *testing.T, path, want string, msg string) {
+	content, err := ioutil.ReadFile(path)
+	if assert.NoError(t, err, "reading %s should succeed", path) {
+		assert.Equal(t, want, string(content), msg)
+	}
+}
+
+func TestReopenCurrentFile(t *testing.T) {
+	t.Run("Renamed away", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		rl, events, cancel := newReopenLogger(t, dir)
+		defer rl.Close()
+		defer cancel()
+
+		current := rl.CurrentFileName()
+		moved := filepath.Join(dir, "moved")
+		if !assert.NoError(t, os.Rename(current, moved)) {
+			return
+		}
+		rl.Write([]byte("second\n"))
+
+		if e, ok := waitEvent(t, events, rotatelogs.FileRotatedEventType).(*rotatelogs.FileRotatedEvent); ok {
+			assert.Equal(t, rotatelogs.RotationReasonFileMissing, e.Reason())
+		}
+		assertContent(t, moved, "first\n", "the moved file should keep what was written before")
+		assertContent(t, rl.CurrentFileName(), "second\n", "writes should go to a new file")
+	})
+
+	t.Run("Replaced", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		rl, events, cancel := newReopenLogger(t, dir)
+		defer rl.Close()
+		defer cancel()
+
+		current := rl.CurrentFileName()
+		moved := filepath.Join(dir, "moved")
+		if !assert.NoError(t, os.Rename(current, moved)) {
+			return
+		}
+		if !assert.NoError(t, ioutil.WriteFile(current, nil, 0644)) {
+			return
+		}
+		rl.Write([]byte("second\n"))
+
+		if e, ok := waitEvent(t, events, rotatelogs.FileReopenedEventType).(*rotatelogs.FileReopenedEvent); ok {
+			assert.Equal(t, rotatelogs.ReopenReasonReplaced, e.Reason())
+			assert.Equal(t, current, e.File())
+		}
+		assertContent(t, moved, "first\n", "the moved file should keep what was written before")
+		assertContent(t, current, "second\n", "writes should go to the replacement")
+		assert.EqualValues(t, 1, rl.Stats().Reopens)
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		rl, events, cancel := newReopenLogger(t, dir)
+		defer rl.Close()
+		defer cancel()
+
+		current := rl.CurrentFileName()
+		if !assert.NoError(t, os.Truncate(current, 0)) {
+			return
+		}
+		rl.Write([]byte("second\n"))
+
+		if e, ok := waitEvent(t, events, rotatelogs.FileReopenedEventType).(*rotatelogs.FileReopenedEvent); ok {
+			assert.Equal(t, rotatelogs.ReopenReasonTruncated, e.Reason())
+		}
+		assertContent(t, current, "second\n", "writes should start at the beginning of the truncated file")
+	})
+
+	t.Run("Deleted", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		rl, events, cancel := newReopenLogger(t, dir)
+		defer rl.Close()
+		defer cancel()
+
+		current := rl.CurrentFileName()
+		if !assert.NoError(t, os.Remove(current)) {
+			return
+		}
+		rl.Write([]byte("second\n"))
+
+		if e, ok := waitEvent(t, events, rotatelogs.FileRotatedEventType).(*rotatelogs.FileRotatedEvent); ok {
+			assert.Equal(t, rotatelogs.RotationReasonFileMissing, e.Reason())
+		}
+		assertContent(t, rl.CurrentFileName(), "second\n", "the file should be created again")
+	})
+
+	t.Run("Failures are counted", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		rl, _, cancel := newReopenLogger(t, dir)
+		defer rl.Close()
+		defer cancel()
+
+		// a directory in place of the file cannot be opened for writing
+		current := rl.CurrentFileName()
+		if !assert.NoError(t, os.Rename(current, filepath.Join(dir, "moved"))) {
+			return
+		}
+		if !assert.NoError(t, os.Mkdir(current, 0755)) {
+			return
+		}
+		assert.Error(t, rl.Reopen(), "reopening a directory should fail")
+		rl.Write([]byte("second\n"))
+
+		stats := rl.Stats()
+		assert.EqualValues(t, 2, stats.ReopenFailures, "both the manual and the automatic reopen should be counted")
+		assert.EqualValues(t, 0, stats.Reopens)
+		assert.Contains(t, stats.LastError, "failed to reopen")
+	})
+}
@@ -0,0 +1,139 @@
+package rotatelogs
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DeleteHook is consulted before retention deletes (or quarantines) a
+// log file. Returning a non-nil error vetoes the deletion and the file is
+// evaluated again on the next maintenance run. Returning an error created
+// by RetryAfter also postpones the next evaluation until the given delay
+// has elapsed.
+type DeleteHook interface {
+	BeforeDelete(path string) error
+}
+
+type DeleteHookFunc func(path string) error
+
+func (f DeleteHookFunc) BeforeDelete(path string) error {
+	return f(path)
+}
+
+// RetryAfterError is returned by a DeleteHook that wants the deletion of
+// a file postponed for at least Delay.
+type RetryAfterError struct {
+	Delay time.Duration
+	Err   error
+}
+
+// RetryAfter creates a RetryAfterError. err may be nil.
+func RetryAfter(delay time.Duration, err error) error {
+	return &RetryAfterError{Delay: delay, Err: err}
+}
+
+func (e *RetryAfterError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("deletion postponed for %s", e.Delay)
+	}
+	return fmt.Sprintf("deletion postponed for %s: %s", e.Delay, e.Err)
+}
+
+// VetoedFile describes a file whose deletion was vetoed by the DeleteHook
+// and which is still waiting to be deleted.
+type VetoedFile struct {
+	Path     string
+	Err      error     // the error returned by the hook
+	Vetoes   int       // number of times the deletion was vetoed
+	RetryAt  time.Time // zero if the file is evaluated on every run
+	LastTime time.Time // time of the latest veto
+}
+
+type vetoTracker struct {
+	mutex sync.Mutex
+	files map[string]*VetoedFile
+	total uint64
+}
+
+// allow reports whether path may be deleted now, consulting hook if the
+// file is not postponed. The hook is called without the lock held, so it
+// may itself call VetoedFiles or VetoCount.
+func (v *vetoTracker) allow(hook DeleteHook, path string, now time.Time) bool {
+	v.mutex.Lock()
+	if vf, ok := v.files[path]; ok && now.Before(vf.RetryAt) {
+		v.mutex.Unlock()
+		return false
+	}
+	v.mutex.Unlock()
+
+	err := hook.BeforeDelete(path)
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if err == nil {
+		delete(v.files, path)
+		return true
+	}
+
+	if v.files == nil {
+		v.files = make(map[string]*VetoedFile)
+	}
+	vf, ok := v.files[path]
+	if !ok {
+		vf = &VetoedFile{Path: path}
+		v.files[path] = vf
+	}
+	vf.Err = err
+	vf.Vetoes++
+	vf.LastTime = now
+	vf.RetryAt = time.Time{}
+	if ra, ok := err.(*RetryAfterError); ok {
+		vf.RetryAt = now.Add(ra.Delay)
+	}
+	v.total++
+	return false
+}
+
+// forget drops the files that are no longer retention candidates.
+func (v *vetoTracker) forget(candidates map[string]struct{}) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	for path := range v.files {
+		if _, ok := candidates[path]; !ok {
+			delete(v.files, path)
+		}
+	}
+}
+
+func (v *vetoTracker) list() []VetoedFile {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	files := make([]VetoedFile, 0, len(v.files))
+	for _, vf := range v.files {
+		files = append(files, *vf)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files
+}
+
+func (v *vetoTracker) count() uint64 {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.total
+}
+
+// VetoedFiles lists the files whose deletion is currently held back by
+// the DeleteHook.
+func (rl *RotateLogs) VetoedFiles() []VetoedFile {
+	return rl.vetoes.list()
+}
+
+// VetoCount returns the total number of deletions vetoed by the
+// DeleteHook since the object was created.
+func (rl *RotateLogs) VetoCount() uint64 {
+	return rl.vetoes.count()
+}
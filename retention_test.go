@@ -0,0 +1,101 @@
+package rotatelogs_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteHook(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	old := testTime.AddDate(0, 0, -3)
+	vetoed := writeLogFile(t, dir, old, "vetoed", old)
+	postponed := writeLogFile(t, dir, old.AddDate(0, 0, -1), "postponed", old)
+
+	var rl *rotatelogs.RotateLogs
+	calls := map[string]int{}
+	allow := false
+	hook := rotatelogs.DeleteHookFunc(func(path string) error {
+		calls[filepath.Base(path)]++
+		// the hook may inspect the logger without deadlocking
+		rl.VetoedFiles()
+		rl.VetoCount()
+		if allow {
+			return nil
+		}
+		if path == postponed {
+			return rotatelogs.RetryAfter(time.Hour, errors.New("busy"))
+		}
+		return errors.New("not shipped yet")
+	})
+
+	clock := clockwork.NewFakeClockAt(testTime)
+	rl = newLogger(t, dir,
+		rotatelogs.WithClock(clock),
+		rotatelogs.WithMaxAgeDuration(24*time.Hour),
+		rotatelogs.WithDeleteHook(hook),
+	)
+	defer rl.Close()
+
+	run := func() bool {
+		done := make(chan error, 1)
+		go func() { done <- rl.RunMaintenance() }()
+		select {
+		case err := <-done:
+			return assert.NoError(t, err, "maintenance should succeed")
+		case <-time.After(5 * time.Second):
+			return assert.Fail(t, "maintenance did not complete, the hook deadlocked")
+		}
+	}
+
+	t.Run("Vetoed files are kept", func(t *testing.T) {
+		if !run() {
+			return
+		}
+		assert.FileExists(t, vetoed)
+		assert.FileExists(t, postponed)
+		assert.EqualValues(t, 2, rl.VetoCount())
+
+		files := rl.VetoedFiles()
+		if !assert.Len(t, files, 2) {
+			return
+		}
+		// sorted by path: the postponed file is a day older
+		assert.Equal(t, postponed, files[0].Path)
+		assert.True(t, files[0].RetryAt.Equal(testTime.Add(time.Hour)), "RetryAfter should postpone the next evaluation")
+		assert.IsType(t, &rotatelogs.RetryAfterError{}, files[0].Err)
+		assert.Equal(t, vetoed, files[1].Path)
+		assert.True(t, files[1].RetryAt.IsZero(), "a plain error should be retried on the next run")
+		assert.EqualError(t, files[1].Err, "not shipped yet")
+		assert.Equal(t, 1, files[1].Vetoes)
+	})
+
+	t.Run("Postponed files are not evaluated before the delay", func(t *testing.T) {
+		if !run() {
+			return
+		}
+		assert.Equal(t, 1, calls[filepath.Base(postponed)], "the hook should not be consulted while postponed")
+		assert.Equal(t, 2, calls[filepath.Base(vetoed)])
+		assert.EqualValues(t, 3, rl.VetoCount())
+	})
+
+	t.Run("Allowed files are deleted", func(t *testing.T) {
+		allow = true
+		clock.Advance(2 * time.Hour)
+		if !run() {
+			return
+		}
+		assertNoFile(t, vetoed)
+		assertNoFile(t, postponed)
+		assert.Len(t, rl.VetoedFiles(), 0, "deleted files should no longer be reported")
+		assert.EqualValues(t, 3, rl.VetoCount(), "the count covers every veto since creation")
+	})
+}
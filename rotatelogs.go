@@ -7,14 +7,12 @@
 package rotatelogs
 
 import (
-	"fmt"
 	"github.com/chriszhangmq/file-rotatelogs/internal/common"
 	"github.com/chriszhangmq/file-rotatelogs/internal/timeutil"
 	"github.com/robfig/cron"
 	"io"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -35,19 +33,33 @@ func New(options ...Option) (*RotateLogs, error) {
 	var rotationTime int
 	var rotationSize int64
 	var rotationCount uint
-	var maxAge int
+	var maxAge time.Duration
 	var handler Handler
 	var filePath string
 	var fileName string
 	var compressFile bool
 	var cronTime string
+	var trashGracePeriod time.Duration
+	var deleteHook DeleteHook
+	var ageSource AgeSource
+	var delivery EventDelivery
+	var postRotate *PostRotateCommand
+	var shipperConfig *ShipperConfig
+	var header HeaderFunc
+	var footer FooterFunc
+	footerOnClose := true
 
 	for _, o := range options {
 		switch o.Name() {
 		case optkeyClock:
 			clock = o.Value().(Clock)
 		case optkeyMaxAge:
-			maxAge = o.Value().(int)
+			switch v := o.Value().(type) {
+			case int:
+				maxAge = time.Duration(v*24) * time.Hour
+			case time.Duration:
+				maxAge = v
+			}
 			if maxAge < 0 {
 				maxAge = 0
 			}
@@ -57,7 +69,12 @@ func New(options ...Option) (*RotateLogs, error) {
 				rotationTime = 0
 			}
 		case optkeyRotationSize:
-			rotationSize = int64(o.Value().(int))
+			switch v := o.Value().(type) {
+			case int:
+				rotationSize = int64(v) * 1024 * 1024
+			case int64:
+				rotationSize = v
+			}
 			if rotationSize < 0 {
 				rotationSize = 0
 			}
@@ -73,6 +90,29 @@ func New(options ...Option) (*RotateLogs, error) {
 			compressFile = o.Value().(bool)
 		case optkeyCronTime:
 			cronTime = o.Value().(string)
+		case optkeyTrashGracePeriod:
+			trashGracePeriod = o.Value().(time.Duration)
+			if trashGracePeriod < 0 {
+				trashGracePeriod = 0
+			}
+		case optkeyDeleteHook:
+			deleteHook = o.Value().(DeleteHook)
+		case optkeyAgeSource:
+			ageSource = o.Value().(AgeSource)
+		case optkeyEventDelivery:
+			delivery = o.Value().(EventDelivery)
+		case optkeyPostRotate:
+			cmd := o.Value().(PostRotateCommand)
+			postRotate = &cmd
+		case optkeyHeader:
+			header = o.Value().(HeaderFunc)
+		case optkeyFooter:
+			footer = o.Value().(FooterFunc)
+		case optkeyFooterOnClose:
+			footerOnClose = o.Value().(bool)
+		case optkeyShipper:
+			cfg := o.Value().(ShipperConfig)
+			shipperConfig = &cfg
 		}
 	}
 
@@ -85,31 +125,52 @@ func New(options ...Option) (*RotateLogs, error) {
 	}
 
 	p := filePath + fileName + "-" + common.TimeFormat
-	globLogPattern := p
-	for _, re := range patternConversionRegexps {
-		globLogPattern = re.ReplaceAllString(globLogPattern, "*")
-	}
-
 	pattern, err := strftime.New(p)
 	if err != nil {
 		return nil, errors.Wrap(err, `invalid strftime pattern`)
 	}
 
-	return &RotateLogs{
-		clock:          clock,
-		eventHandler:   handler,
-		globLogPattern: globLogPattern,
-		linkName:       filePath + fileName,
-		maxAge:         time.Duration(maxAge*24) * time.Hour,
-		pattern:        pattern,
-		rotationTime:   time.Duration(rotationTime*24) * time.Hour,
-		rotationSize:   rotationSize * 1024 * 1024,
-		rotationCount:  rotationCount,
-		fileName:       fileName,
-		filePath:       filePath,
-		compressFile:   compressFile,
-		cronTime:       cronTime,
-	}, nil
+	matcher := fileutil.NewFileMatcher(filePath, fileName, common.TimeFormat)
+	pinsPath := filepath.Join(matcher.Dir(), "."+filepath.Base(filePath+fileName)+common.PinsSuffix)
+	trashPath := filepath.Join(matcher.Dir(), common.TrashDir, "."+filepath.Base(filePath+fileName)+common.TrashIndexSuffix)
+
+	diag := &diagOutput{}
+	rl := &RotateLogs{
+		clock:            clock,
+		diag:             diag,
+		events:           newDispatcher(handler, delivery, diag),
+		matcher:          matcher,
+		linkName:         filePath + fileName,
+		maxAge:           maxAge,
+		ageSource:        ageSource,
+		pattern:          pattern,
+		rotationTime:     time.Duration(rotationTime*24) * time.Hour,
+		rotationSize:     rotationSize,
+		rotationCount:    rotationCount,
+		fileName:         fileName,
+		filePath:         filePath,
+		compressFile:     compressFile,
+		cronTime:         cronTime,
+		trashGracePeriod: trashGracePeriod,
+		deleteHook:       deleteHook,
+		pins:             pinSet{path: pinsPath},
+		trash:            trashIndex{path: trashPath},
+		header:           header,
+		footer:           footer,
+		footerOnClose:    footerOnClose,
+	}
+	if postRotate != nil {
+		rl.postRotate = newCommandRunner(*postRotate, rl.emit)
+	}
+	if shipperConfig != nil {
+		if shipperConfig.Store == nil {
+			return nil, errors.New("the shipper object store is missing")
+		}
+		journal := filepath.Join(matcher.Dir(), "."+filepath.Base(filePath+fileName)+common.ShipperJournalSuffix)
+		rl.shipper = newShipper(*shipperConfig, journal, compressFile, rl.emit, rl.pins.has)
+		rl.deleteHook = chainDeleteHooks(rl.shipper, rl.deleteHook)
+	}
+	return rl, nil
 }
 
 // Write satisfies the io.Writer interface. It writes to the
@@ -123,10 +184,18 @@ func (rl *RotateLogs) Write(p []byte) (n int, err error) {
 
 	out, err := rl.getWriterNolock(false, false)
 	if err != nil {
-		return 0, errors.Wrap(err, `failed to acquite target io.Writer`)
+		err = errors.Wrap(err, `failed to acquite target io.Writer`)
+		rl.stats.write(0, err, rl.clock.Now())
+		return 0, err
 	}
 
-	return out.Write(p)
+	n, err = out.Write(p)
+	rl.stats.write(n, err, rl.clock.Now())
+	rl.curBytes += int64(n)
+	rl.curSize += int64(n)
+	rl.curWrites++
+	rl.curLastWrite = rl.clock.Now()
+	return n, err
 }
 
 // must be locked during this operation
@@ -136,22 +205,44 @@ func (rl *RotateLogs) getWriterNolock(bailOnRotateFail, useGenerationalNames boo
 	filename := common.IsNull
 	forceNewFile := false
 	sizeRotation := false
+	reason := RotationReasonManual
+	prevSize := rl.curBytes
 	fi, err := os.Stat(rl.curFn)
+	//文件被替换或截断时重新打开
+	if err == nil && rl.outFh != nil {
+		if why, changed := rl.fileChangedNolock(fi); changed {
+			if err := rl.reopenNolock(why); err != nil {
+				rl.diag.printf("%s\n", err.Error())
+			}
+		}
+	}
 	//err != nil说明当前文件不存在
 	if err != nil {
 		//文件不存在
 		forceNewFile = true
+		reason = RotationReasonFileMissing
+		if rl.curFn == common.IsNull {
+			reason = RotationReasonStartup
+		}
 	} else if rl.rotationSize > 0 && rl.rotationSize <= fi.Size() {
 		//是否需要按照大小分割文件：文件存在，且文件大小超过设定阈值。
 		forceNewFile = true
 		sizeRotation = true
+		reason = RotationReasonSize
 	} else if !sizeRotation && rl.rotationTime > 0 {
 		//文件存在：判断当前文件是否需要按天的分割
-		currFileTime := fileutil.ParseTimeFromFileName(common.TimeFormat, rl.curFn, rl.clock.Now())
+		currFileTime, _ := rl.matcher.ParseTime(rl.curFn, rl.clock.Now().Location())
 		if timeutil.CompareTimeWithDay(rl.clock.Now().Add(-1*rl.rotationTime), currFileTime) {
 			forceNewFile = true
+			reason = RotationReasonTime
 		}
 	}
+	if fi != nil {
+		prevSize = fi.Size()
+	}
+	if useGenerationalNames && rl.curFn != common.IsNull {
+		reason = RotationReasonManual
+	}
 	//不需要分割
 	if !forceNewFile && !sizeRotation && !useGenerationalNames {
 		return rl.outFh, nil
@@ -160,15 +251,26 @@ func (rl *RotateLogs) getWriterNolock(bailOnRotateFail, useGenerationalNames boo
 	if forceNewFile {
 		//按照天、文件大小分割文件：获取新的文件名
 		filename = fileutil.GetNewFileName(rl.filePath, rl.fileName, rl.rotationSize, rl.clock)
+	} else {
+		//手动分割：使用下一个不存在的文件名
+		filename = fileutil.GetNextFileName(rl.filePath, rl.fileName, rl.clock)
 	}
 
+	sfi, statErr := os.Stat(filename)
+	isEmpty := os.IsNotExist(statErr) || (statErr == nil && sfi.Size() == 0)
 	fh, err := fileutil.CreateFile(filename)
 	if err != nil {
-		return nil, errors.Wrapf(err, `failed to create a new file %v`, filename)
+		err = errors.Wrapf(err, `failed to create a new file %v`, filename)
+		rl.emit(&RotationFailedEvent{file: filename, err: err, time: rl.clock.Now()})
+		return nil, err
+	}
+	if os.IsNotExist(statErr) {
+		rl.emit(&FileCreatedEvent{file: filename, time: rl.clock.Now()})
 	}
 
 	if err := rl.rotateNolock(filename); err != nil {
 		err = errors.Wrap(err, "failed to rotate")
+		rl.emit(&RotationFailedEvent{file: filename, err: err, time: rl.clock.Now()})
 		if bailOnRotateFail {
 			if fh != nil { // probably can't happen, but being paranoid
 				fh.Close()
@@ -176,24 +278,118 @@ func (rl *RotateLogs) getWriterNolock(bailOnRotateFail, useGenerationalNames boo
 
 			return nil, err
 		}
-		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		rl.diag.printf("%s\n", err.Error())
+	}
+
+	prevSize += rl.writeFooterNolock()
+
+	now := rl.clock.Now()
+	ev := &FileRotatedEvent{
+		prev:       previousFn,
+		current:    filename,
+		time:       now,
+		reason:     reason,
+		prevSize:   prevSize,
+		prevWrites: rl.curWrites,
+		start:      rl.curStart,
+		end:        rl.curLastWrite,
+	}
+	if ev.end.IsZero() {
+		ev.end = ev.start
 	}
 
 	rl.outFh.Close()
 	rl.outFh = fh
 	rl.curFn = filename
 	rl.generation = generation
+	rl.curBytes = 0
+	rl.curSize = 0
+	if statErr == nil {
+		rl.curSize = sfi.Size()
+	}
+	rl.curWrites = 0
+	rl.curStart = now
+	rl.curLastWrite = time.Time{}
+	if isEmpty {
+		rl.writeHeaderNolock()
+	}
 
-	if h := rl.eventHandler; h != nil {
-		go h.Handle(&FileRotatedEvent{
-			prev:    previousFn,
-			current: filename,
-		})
+	rl.emit(ev)
+	if previousFn != common.IsNull {
+		rl.postRotate.trigger(FileRotatedEventType, previousFn)
 	}
 
 	return fh, nil
 }
 
+// writeHeaderNolock writes the header of a newly created file.
+// must be locked during this operation
+func (rl *RotateLogs) writeHeaderNolock() {
+	if rl.header == nil {
+		return
+	}
+	w := &countingWriter{w: rl.outFh}
+	if err := rl.header(w, rl.curFn); err != nil {
+		rl.diag.printf("%s\n", errors.Wrapf(err, `failed to write header to %s`, rl.curFn))
+	}
+	rl.curBytes += w.n
+}
+
+// writeFooterNolock writes the footer of the current file before the
+// object rotates away from it or closes it and returns the number of bytes
+// written.
+// must be locked during this operation
+func (rl *RotateLogs) writeFooterNolock() int64 {
+	if rl.footer == nil || rl.outFh == nil {
+		return 0
+	}
+	summary := FileSummary{
+		Filename: rl.curFn,
+		Bytes:    rl.curBytes,
+		Writes:   rl.curWrites,
+		Start:    rl.curStart,
+		End:      rl.curLastWrite,
+	}
+	if summary.End.IsZero() {
+		summary.End = summary.Start
+	}
+	w := &countingWriter{w: rl.outFh}
+	if err := rl.footer(w, summary); err != nil {
+		rl.diag.printf("%s\n", errors.Wrapf(err, `failed to write footer to %s`, rl.curFn))
+	}
+	return w.n
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// emit delivers an event to the registered Handler, if any
+func (rl *RotateLogs) emit(e Event) {
+	rl.stats.observe(e)
+	rl.shipper.observe(e)
+	rl.events.dispatch(e)
+}
+
+// Sync commits the contents of the current file to
+// stable storage.
+func (rl *RotateLogs) Sync() error {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	if rl.outFh == nil {
+		return nil
+	}
+	return rl.outFh.Sync()
+}
+
 // CurrentFileName returns the current file name that
 // the RotateLogs object is writing to
 func (rl *RotateLogs) CurrentFileName() string {
@@ -203,10 +399,10 @@ func (rl *RotateLogs) CurrentFileName() string {
 	return rl.curFn
 }
 
-var patternConversionRegexps = []*regexp.Regexp{
-	regexp.MustCompile(`-[0-9]+`),
-	regexp.MustCompile(`%[%+A-Za-z]`),
-	regexp.MustCompile(`\*+`),
+// Location returns the time zone of the object's clock, in which file
+// names are dated and the maintenance schedule is evaluated.
+func (rl *RotateLogs) Location() *time.Location {
+	return rl.clock.Now().Location()
 }
 
 type cleanupGuard struct {
@@ -240,6 +436,97 @@ func (rl *RotateLogs) Rotate() error {
 	return err
 }
 
+// Reopen closes the current file and opens it again by name, e.g. after
+// an external tool such as logrotate moved it away. Unlike Rotate it does
+// not switch to a new file name, and no footer is written. If the file no
+// longer exists it is created, with its header.
+//
+// Writes already reopen the file on their own when they notice that it
+// was replaced or truncated; Reopen is for doing so right away.
+func (rl *RotateLogs) Reopen() error {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	return rl.reopenNolock(ReopenReasonManual)
+}
+
+// fileChangedNolock reports whether the current file was replaced or
+// truncated behind the object's back. fi describes the file currently
+// found under the name.
+// must be locked during this operation
+func (rl *RotateLogs) fileChangedNolock(fi os.FileInfo) (ReopenReason, bool) {
+	ofi, err := rl.outFh.Stat()
+	if err != nil {
+		return 0, false
+	}
+	if !os.SameFile(fi, ofi) {
+		return ReopenReasonReplaced, true
+	}
+	if fi.Size() < rl.curSize {
+		return ReopenReasonTruncated, true
+	}
+	return 0, false
+}
+
+// must be locked during this operation
+func (rl *RotateLogs) reopenNolock(reason ReopenReason) error {
+	if rl.outFh == nil || rl.curFn == common.IsNull {
+		// nothing is open, the next write opens the file
+		return nil
+	}
+	_, statErr := os.Stat(rl.curFn)
+	fh, err := fileutil.CreateFile(rl.curFn)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to reopen %s", rl.curFn)
+		rl.stats.reopenFailed(err, rl.clock.Now())
+		return err
+	}
+	if os.IsNotExist(statErr) {
+		rl.emit(&FileCreatedEvent{file: rl.curFn, time: rl.clock.Now()})
+	}
+	rl.outFh.Close()
+	rl.outFh = fh
+	rl.curSize = 0
+	if fi, err := fh.Stat(); err == nil {
+		rl.curSize = fi.Size()
+	}
+	rl.emit(&FileReopenedEvent{file: rl.curFn, reason: reason, size: rl.curSize, time: rl.clock.Now()})
+	if rl.curSize == 0 {
+		rl.writeHeaderNolock()
+	}
+	return nil
+}
+
+// closeHook is a function registered with onClose. It is referred to by
+// pointer so that it can be unregistered.
+type closeHook struct {
+	fn func()
+}
+
+// onClose registers fn to be called once by Close. The returned function
+// unregisters it. Registering fails once the object has been closed.
+func (rl *RotateLogs) onClose(fn func()) (remove func(), err error) {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	if rl.closed {
+		return nil, errors.New("the logger is closed")
+	}
+	hook := &closeHook{fn: fn}
+	rl.closeHooks = append(rl.closeHooks, hook)
+	return func() {
+		rl.mutex.Lock()
+		defer rl.mutex.Unlock()
+
+		for i, h := range rl.closeHooks {
+			if h == hook {
+				rl.closeHooks = append(rl.closeHooks[:i], rl.closeHooks[i+1:]...)
+				return
+			}
+		}
+	}, nil
+}
+
 func (rl *RotateLogs) rotateNolock(filename string) error {
 	lockfn := filename + common.LockSuffix
 	fh, err := os.OpenFile(lockfn, os.O_CREATE|os.O_EXCL, 0644)
@@ -292,35 +579,54 @@ func (rl *RotateLogs) rotateNolock(filename string) error {
 		}
 	}
 
-	if rl.maxAge <= 0 && rl.rotationCount <= 0 {
-		return errors.New("panic: maxAge and rotationCount are both set")
-	}
-
 	return nil
 }
 
 // Close satisfies the io.Closer interface. You must
 // call this method if you performed any writes to
-// the object.
+// the object. Events that are still queued are
+// delivered before Close returns.
 func (rl *RotateLogs) Close() error {
+	// release what was tied to the object, e.g. by HandleSignals, before
+	// closing the file so that no action runs half way through
 	rl.mutex.Lock()
-	defer rl.mutex.Unlock()
+	hooks := rl.closeHooks
+	rl.closeHooks = nil
+	rl.closed = true
+	rl.mutex.Unlock()
+	for _, h := range hooks {
+		h.fn()
+	}
 
-	if rl.outFh == nil {
-		return nil
+	rl.mutex.Lock()
+	if rl.outFh != nil {
+		if rl.footerOnClose {
+			rl.writeFooterNolock()
+		}
+		rl.outFh.Close()
+		rl.outFh = nil
 	}
+	cronObj := rl.cron
+	rl.cron = nil
+	rl.mutex.Unlock()
 
-	rl.outFh.Close()
-	rl.outFh = nil
+	if cronObj != nil {
+		cronObj.Stop()
+	}
+
+	// drain outside of the lock, handlers may call back into rl
+	rl.postRotate.wait()
+	rl.shipper.stop()
+	rl.events.close()
 
 	return nil
 }
 
-//删除所有_lock、_symlink文件
+// 删除所有_lock、_symlink文件
 func (rl *RotateLogs) deleteLockSymlinkFile() {
-	matches, err := filepath.Glob(rl.globLogPattern)
+	matches, err := rl.matcher.Glob()
 	if err != nil {
-		fmt.Println(err)
+		rl.diag.printf("%s\n", err.Error())
 	}
 	removeFiles := make([]string, 0, len(matches))
 	for _, path := range matches {
@@ -333,11 +639,11 @@ func (rl *RotateLogs) deleteLockSymlinkFile() {
 	}
 }
 
-//清除已被压缩的.log文件
-func (rl *RotateLogs) deleteSameLogFile() error {
-	matches, err := filepath.Glob(rl.globLogPattern)
+// 清除已被压缩的.log文件
+func (rl *RotateLogs) deleteSameLogFile() (int, error) {
+	matches, err := rl.matcher.Glob()
 	if err != nil {
-		return err
+		return 0, err
 	}
 	removeSuffixFilesMap := make(map[string]string, len(matches))
 	for _, path := range matches {
@@ -352,22 +658,35 @@ func (rl *RotateLogs) deleteSameLogFile() error {
 		if strings.HasSuffix(path, common.CompressSuffix) {
 			continue
 		}
+		if rl.pins.has(path) {
+			continue
+		}
 		if _, ok := removeSuffixFilesMap[path]; ok {
 			removeFiles = append(removeFiles, path)
 		}
 	}
+	deleted := 0
 	for _, path := range removeFiles {
-		os.Remove(path)
+		fi, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			continue
+		}
+		deleted++
+		rl.emit(&FileDeletedEvent{file: path, size: fi.Size(), time: rl.clock.Now()})
 	}
-	return nil
+	return deleted, nil
 }
 
-//压缩日志文件
-func (rl *RotateLogs) compressLogFiles() error {
-	matches, err := filepath.Glob(rl.globLogPattern)
+// 压缩日志文件
+func (rl *RotateLogs) compressLogFiles() (int, error) {
+	matches, err := rl.matcher.Glob()
 	if err != nil {
-		return err
+		return 0, err
 	}
+	curFn := rl.CurrentFileName()
 	files := make([]string, 0, len(matches))
 	for _, path := range matches {
 		// Ignore lock files
@@ -385,23 +704,99 @@ func (rl *RotateLogs) compressLogFiles() error {
 		if fl.Mode()&os.ModeSymlink == os.ModeSymlink {
 			continue
 		}
-		fiName2Time := fileutil.ParseTimeFromFileName(common.TimeFormat, fi.Name(), rl.clock.Now())
-		if fi.Name() != rl.curFn && !timeutil.IsToday(fiName2Time, rl.clock.Now()) {
-			files = append(files, fi.Name())
+		if rl.pins.has(path) {
+			continue
+		}
+		fiName2Time, err := rl.matcher.ParseTime(path, rl.clock.Now().Location())
+		if err != nil {
+			fiName2Time = fi.ModTime()
+		}
+		if path != curFn && !timeutil.IsToday(fiName2Time, rl.clock.Now()) {
+			files = append(files, path)
 		}
 	}
-	fileutil.CompressLogFiles(files, rl.filePath)
-	return nil
+	compressed := 0
+	for _, path := range files {
+		if rl.compressLogFile(path) {
+			compressed++
+		}
+	}
+	return compressed, nil
 }
 
-//删除文件: .log 、 .gz
-func (rl *RotateLogs) deleteFile() error {
-	matches, err := filepath.Glob(rl.globLogPattern)
+// compressLogFile gzips a single file and reports the outcome as an event
+func (rl *RotateLogs) compressLogFile(path string) bool {
+	start := time.Now()
+	dst := path + common.CompressSuffix
+	fi, err := os.Stat(path)
+	if err == nil {
+		err = fileutil.CompressLogFile(path, dst)
+	}
 	if err != nil {
-		return err
+		rl.emit(&CompressionFailedEvent{file: path, err: err, time: rl.clock.Now()})
+		return false
+	}
+	var compressedSize int64
+	if gzfi, err := os.Stat(dst); err == nil {
+		compressedSize = gzfi.Size()
+	}
+	rl.emit(&FileCompressedEvent{
+		source:         path,
+		file:           dst,
+		originalSize:   fi.Size(),
+		compressedSize: compressedSize,
+		duration:       time.Since(start),
+		time:           rl.clock.Now(),
+	})
+	rl.postRotate.trigger(FileCompressedEventType, dst)
+	return true
+}
+
+// 删除文件: .log 、 .gz
+func (rl *RotateLogs) deleteFile() (int, error) {
+	removeFiles, sizes, err := rl.expiredFiles()
+	if err != nil {
+		return 0, err
+	}
+	if rl.deleteHook != nil {
+		candidates := make(map[string]struct{}, len(removeFiles))
+		for _, path := range removeFiles {
+			candidates[path] = struct{}{}
+		}
+		rl.vetoes.forget(candidates)
+	}
+	deleted := 0
+	for _, path := range removeFiles {
+		if rl.deleteHook != nil && !rl.vetoes.allow(rl.deleteHook, path, rl.clock.Now()) {
+			continue
+		}
+		if err := rl.removeFile(path); err != nil {
+			rl.diag.printf("%s\n", err.Error())
+			continue
+		}
+		deleted++
+		rl.emit(&FileDeletedEvent{
+			file:        path,
+			size:        sizes[path],
+			quarantined: rl.trashGracePeriod > 0,
+			retention:   true,
+			time:        rl.clock.Now(),
+		})
+	}
+	return deleted, nil
+}
+
+// expiredFiles returns the files retention would delete now, before the
+// DeleteHook is consulted, and their sizes
+func (rl *RotateLogs) expiredFiles() ([]string, map[string]int64, error) {
+	matches, err := rl.matcher.Glob()
+	if err != nil {
+		return nil, nil, err
 	}
 	removeFiles := make([]string, 0, len(matches))
+	sizes := make(map[string]int64, len(matches))
 	cutoff := rl.clock.Now().Add(-1 * rl.maxAge)
+	curFn := rl.CurrentFileName()
 	for _, path := range matches {
 		// Ignore lock files
 		if strings.HasSuffix(path, common.LockSuffix) || strings.HasSuffix(path, common.SymlinkSuffix) {
@@ -419,16 +814,42 @@ func (rl *RotateLogs) deleteFile() error {
 		if fl.Mode()&os.ModeSymlink == os.ModeSymlink {
 			continue
 		}
-		//按天数判断是否保留
-		fiName2Time := fileutil.ParseTimeFromFileName(common.TimeFormat, fi.Name(), rl.clock.Now())
-		if rl.maxAge > 0 && timeutil.IsMaxDay(cutoff, fiName2Time) {
+		if rl.pins.has(path) {
+			continue
+		}
+		if path == curFn {
+			continue
+		}
+		//按文件时间判断是否保留
+		if rl.maxAge > 0 && !rl.fileAgeTime(path, fi).After(cutoff) {
 			removeFiles = append(removeFiles, path)
+			sizes[path] = fi.Size()
 		}
 	}
-	for _, path := range removeFiles {
-		os.Remove(path)
+	return removeFiles, sizes, nil
+}
+
+// ExpiredFiles returns the files that retention would delete if it ran
+// now, without deleting them. Files a DeleteHook may still veto are
+// included.
+func (rl *RotateLogs) ExpiredFiles() ([]string, error) {
+	files, _, err := rl.expiredFiles()
+	return files, err
+}
+
+// fileAgeTime returns the time a file's age is measured from. A date
+// parsed from the file name covers the whole day, so the file is only
+// considered to age from the end of that day. Names that cannot be parsed
+// fall back to the modification time.
+func (rl *RotateLogs) fileAgeTime(path string, fi os.FileInfo) time.Time {
+	if rl.ageSource == AgeFromModTime {
+		return fi.ModTime()
 	}
-	return nil
+	t, err := rl.matcher.ParseTime(path, rl.clock.Now().Location())
+	if err != nil {
+		return fi.ModTime()
+	}
+	return t.AddDate(0, 0, 1)
 }
 
 // 定时任务
@@ -436,30 +857,77 @@ func (rl *RotateLogs) cronTask(cronTime string) {
 	cronObj := cron.NewWithLocation(rl.clock.Now().Location())
 	err := cronObj.AddFunc(cronTime, rl.cronFunc)
 	if err != nil {
-		fmt.Println(err)
+		rl.diag.printf("%s\n", err.Error())
 	}
+	rl.mutex.Lock()
+	rl.cron = cronObj
+	rl.mutex.Unlock()
 	cronObj.Start()
 }
 
 func (rl *RotateLogs) cronFunc() {
-	go func() {
-		//删除过期文件
-		if rl.maxAge > 0 {
-			if err := rl.deleteFile(); err != nil {
-				fmt.Println(err)
-			}
-		}
-		//删除已解压的文件
-		if err := rl.deleteSameLogFile(); err != nil {
-			fmt.Println(err)
-		}
-		//压缩非当天文件
-		if rl.compressFile {
-			if err := rl.compressLogFiles(); err != nil {
-				fmt.Println(err)
+	go rl.runMaintenance()
+}
+
+// RunMaintenance applies retention and compression once, synchronously.
+// It is what the scheduler started by Init runs; call it directly when
+// several loggers share one scheduler instead of each running its own.
+// The returned error is the first failure of the run, if any.
+func (rl *RotateLogs) RunMaintenance() error {
+	return rl.runMaintenance()
+}
+
+// RunRetention applies only the retention policy once, synchronously:
+// the files listed by ExpiredFiles are deleted or quarantined unless the
+// DeleteHook vetoes them, and the files listed by ExpiredTrash are
+// purged. Unlike RunMaintenance it neither compresses files nor removes
+// the uncompressed copies of compressed ones.
+func (rl *RotateLogs) RunRetention() error {
+	return rl.maintain(false)
+}
+
+// runMaintenance applies retention and compression once
+func (rl *RotateLogs) runMaintenance() error {
+	return rl.maintain(true)
+}
+
+// maintain applies retention and, if full, compression and the removal
+// of leftover copies once, and reports the run with a
+// MaintenanceCompletedEvent
+func (rl *RotateLogs) maintain(full bool) error {
+	ev := &MaintenanceCompletedEvent{start: rl.clock.Now()}
+	start := time.Now()
+	record := func(n int, err error) int {
+		if err != nil {
+			rl.diag.printf("%s\n", err.Error())
+			if ev.err == nil {
+				ev.err = err
 			}
 		}
-	}()
+		return n
+	}
+	//删除过期文件
+	if rl.maxAge > 0 {
+		ev.deleted += record(rl.deleteFile())
+	}
+	//清除隔离区中的过期文件
+	if rl.trashGracePeriod > 0 {
+		ev.deleted += record(rl.purgeTrash())
+	}
+	if !full {
+		ev.duration = time.Since(start)
+		rl.emit(ev)
+		return ev.err
+	}
+	//删除已解压的文件
+	ev.deleted += record(rl.deleteSameLogFile())
+	//压缩非当天文件
+	if rl.compressFile {
+		ev.compressed += record(rl.compressLogFiles())
+	}
+	ev.duration = time.Since(start)
+	rl.emit(ev)
+	return ev.err
 }
 
 func (rl *RotateLogs) Init() {
@@ -467,5 +935,13 @@ func (rl *RotateLogs) Init() {
 		rl.cronTask(rl.cronTime)
 	}
 	rl.cronFunc()
+	rl.start()
+}
+
+// start does the startup work of Init that does not depend on the
+// scheduler and its first maintenance run: removing stale lock and symlink
+// files, and resuming pending shipments.
+func (rl *RotateLogs) start() {
 	rl.deleteLockSymlinkFile()
+	rl.shipper.start()
 }
@@ -1,3 +1,12 @@
+//go:build upstream
+// +build upstream
+
+// These tests were written for the pattern based API of
+// github.com/lestrrat-go/file-rotatelogs (New(pattern, ...), WithLinkName,
+// ForceNewFile), which this package does not have, and they do not
+// compile against it. The build tag keeps them from breaking the other
+// tests of the package until they are ported.
+
 package rotatelogs_test
 
 import (
@@ -474,6 +483,8 @@ func TestGHIssue23(t *testing.T) {
 					return
 				}
 
+				assert.Equal(t, loc, rl.Location(), "the location should be the one of the clock")
+
 				t.Logf("expected %s", test.Expected)
 				rl.Rotate()
 				if !assert.Equal(t, test.Expected, rl.CurrentFileName(), "file names should match") {
@@ -0,0 +1,468 @@
+package rotatelogs
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chriszhangmq/file-rotatelogs/internal/common"
+	"github.com/chriszhangmq/file-rotatelogs/internal/fileutil"
+	"github.com/pkg/errors"
+)
+
+// ObjectStore is the destination rotated log files are shipped to.
+type ObjectStore interface {
+	// Put stores the size bytes read from r under key, replacing any
+	// existing object with the same key.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+}
+
+// LocalObjectStore is an ObjectStore keeping objects as files below a
+// directory. It is meant for tests and for shipping to mounted volumes.
+type LocalObjectStore struct {
+	dir string
+}
+
+// NewLocalObjectStore creates a LocalObjectStore rooted at dir.
+func NewLocalObjectStore(dir string) *LocalObjectStore {
+	return &LocalObjectStore{dir: dir}
+}
+
+func (s *LocalObjectStore) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	dst := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return errors.Wrapf(err, `failed to create directory %s`, filepath.Dir(dst))
+	}
+	tmp := dst + ".tmp"
+	fh, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrapf(err, `failed to create %s`, tmp)
+	}
+	n, err := io.Copy(fh, r)
+	if cerr := fh.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && n != size {
+		err = errors.Errorf(`short write to %s: %d of %d bytes`, tmp, n, size)
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// ShipperConfig configures the shipping of rotated files to an
+// ObjectStore. Files are shipped once they are final: after rotation,
+// or after compression when compression is enabled.
+//
+// Retention does not delete a file until it has been shipped, unless the
+// shipper gave up on it after MaxAttempts failures. Files that disappear
+// before they could be shipped are dropped from the journal.
+type ShipperConfig struct {
+	Store             ObjectStore
+	Prefix            string        // prepended to the base name to form the object key
+	MaxAttempts       int           // give up after this many failures (0: retry forever)
+	MinBackoff        time.Duration // delay before the first retry (default 1s)
+	MaxBackoff        time.Duration // upper bound of the retry delay (default 5m)
+	DeleteAfterUpload bool          // remove the local file once shipped
+}
+
+// Shipment is the upload state of a file, as recorded in the journal.
+type Shipment struct {
+	Path        string    `json:"path"`
+	Key         string    `json:"key"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error,omitempty"`
+	GaveUp      bool      `json:"gave_up,omitempty"`
+}
+
+// shipper uploads files in the background. Pending uploads are kept in a
+// JSON journal next to the logs so that they resume after a restart, and
+// they veto retention until they have been shipped.
+type shipper struct {
+	cfg      ShipperConfig
+	journal  string
+	compress bool
+	emit     func(Event)
+	isPinned func(string) bool
+
+	mutex   sync.Mutex
+	pending map[string]*Shipment
+	loaded  bool
+	wake    chan struct{}
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+func newShipper(cfg ShipperConfig, journal string, compress bool, emit func(Event), isPinned func(string) bool) *shipper {
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 5 * time.Minute
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = cfg.MinBackoff
+	}
+	return &shipper{
+		cfg:      cfg,
+		journal:  journal,
+		compress: compress,
+		emit:     emit,
+		isPinned: isPinned,
+		pending:  make(map[string]*Shipment),
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+}
+
+// observe enqueues the files that became final according to e
+func (s *shipper) observe(e Event) {
+	if s == nil {
+		return
+	}
+	var path string
+	switch ev := e.(type) {
+	case *FileRotatedEvent:
+		// a missing previous file cannot be shipped
+		if !s.compress && ev.Reason() != RotationReasonFileMissing {
+			path = ev.PreviousFile()
+		}
+	case *FileCompressedEvent:
+		if s.compress {
+			path = ev.File()
+		}
+	case *FileDeletedEvent:
+		s.forget(ev.File())
+	}
+	if path == "" {
+		return
+	}
+	if err := s.enqueue(path); err != nil {
+		s.emit(&ShippingFailedEvent{file: path, err: err, time: time.Now()})
+	}
+}
+
+func (s *shipper) enqueue(path string) error {
+	s.mutex.Lock()
+	if err := s.loadNolock(); err != nil {
+		s.mutex.Unlock()
+		return err
+	}
+	if _, ok := s.pending[path]; !ok {
+		s.pending[path] = &Shipment{
+			Path: path,
+			Key:  s.cfg.Prefix + filepath.Base(path),
+		}
+	}
+	err := s.saveNolock()
+	s.mutex.Unlock()
+
+	s.start()
+	s.signal()
+	return err
+}
+
+// forget drops the entry of a file that no longer exists
+func (s *shipper) forget(path string) {
+	s.mutex.Lock()
+	if err := s.loadNolock(); err != nil {
+		s.mutex.Unlock()
+		return
+	}
+	if _, ok := s.pending[path]; !ok {
+		s.mutex.Unlock()
+		return
+	}
+	delete(s.pending, path)
+	err := s.saveNolock()
+	s.mutex.Unlock()
+
+	if err != nil {
+		s.emit(&ShippingFailedEvent{file: path, err: err, time: time.Now()})
+	}
+}
+
+// must be locked during this operation
+func (s *shipper) loadNolock() error {
+	if s.loaded {
+		return nil
+	}
+	buf, err := ioutil.ReadFile(s.journal)
+	if err != nil {
+		if os.IsNotExist(err) {
+			s.loaded = true
+			return nil
+		}
+		return errors.Wrapf(err, `failed to read shipper journal %s`, s.journal)
+	}
+	var entries []*Shipment
+	if err := json.Unmarshal(buf, &entries); err != nil {
+		return errors.Wrapf(err, `failed to parse shipper journal %s`, s.journal)
+	}
+	for _, entry := range entries {
+		s.pending[entry.Path] = entry
+	}
+	s.loaded = true
+	return nil
+}
+
+// must be locked during this operation
+func (s *shipper) saveNolock() error {
+	entries := make([]*Shipment, 0, len(s.pending))
+	for _, entry := range s.pending {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	buf, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(s.journal, buf, 0644)
+}
+
+func (s *shipper) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// start launches the upload loop, resuming the uploads recorded in the
+// journal by a previous process
+func (s *shipper) start() {
+	if s == nil {
+		return
+	}
+	s.startOnce.Do(func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		s.cancel = cancel
+		go s.loop(ctx)
+	})
+}
+
+func (s *shipper) loop(ctx context.Context) {
+	defer close(s.done)
+
+	for {
+		entry, wait := s.next(time.Now())
+		if entry != nil {
+			s.ship(ctx, entry)
+			continue
+		}
+
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			expired = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-s.wake:
+		case <-expired:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// next returns a copy of the next due shipment, or how long to wait
+// until one becomes due (0 if there is nothing to wait for)
+func (s *shipper) next(now time.Time) (*Shipment, time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.loadNolock(); err != nil {
+		return nil, s.cfg.MaxBackoff
+	}
+	var wait time.Duration
+	for _, entry := range s.pending {
+		if entry.GaveUp {
+			continue
+		}
+		if !entry.NextAttempt.After(now) {
+			cp := *entry
+			return &cp, 0
+		}
+		if d := entry.NextAttempt.Sub(now); wait == 0 || d < wait {
+			wait = d
+		}
+	}
+	return nil, wait
+}
+
+func (s *shipper) ship(ctx context.Context, entry *Shipment) {
+	err := s.upload(ctx, entry)
+	if ctx.Err() != nil {
+		// interrupted by Close, the journal still has the entry
+		return
+	}
+
+	s.mutex.Lock()
+	current, ok := s.pending[entry.Path]
+	if !ok {
+		s.mutex.Unlock()
+		return
+	}
+	current.Attempts++
+	if err == nil {
+		delete(s.pending, entry.Path)
+	} else if os.IsNotExist(errors.Cause(err)) {
+		// retrying cannot bring the file back
+		current.GaveUp = true
+		delete(s.pending, entry.Path)
+	} else {
+		current.LastError = err.Error()
+		if s.cfg.MaxAttempts > 0 && current.Attempts >= s.cfg.MaxAttempts {
+			current.GaveUp = true
+		}
+		current.NextAttempt = time.Now().Add(s.backoff(current.Attempts))
+	}
+	attempts := current.Attempts
+	gaveUp := current.GaveUp
+	saveErr := s.saveNolock()
+	s.mutex.Unlock()
+
+	if err != nil {
+		s.emit(&ShippingFailedEvent{file: entry.Path, key: entry.Key, attempts: attempts, gaveUp: gaveUp, err: err, time: time.Now()})
+		return
+	}
+	if saveErr != nil {
+		s.emit(&ShippingFailedEvent{file: entry.Path, key: entry.Key, attempts: attempts, err: saveErr, time: time.Now()})
+	}
+
+	deleted := false
+	if s.cfg.DeleteAfterUpload && !s.isPinned(entry.Path) {
+		deleted = os.Remove(entry.Path) == nil
+	}
+	s.emit(&FileShippedEvent{file: entry.Path, key: entry.Key, attempts: attempts, deleted: deleted, time: time.Now()})
+}
+
+func (s *shipper) upload(ctx context.Context, entry *Shipment) error {
+	fh, err := os.Open(entry.Path)
+	if err != nil {
+		return errors.Wrapf(err, `failed to open %s`, entry.Path)
+	}
+	defer fh.Close()
+	fi, err := fh.Stat()
+	if err != nil {
+		return errors.Wrapf(err, `failed to stat %s`, entry.Path)
+	}
+	if err := s.cfg.Store.Put(ctx, entry.Key, fh, fi.Size()); err != nil {
+		return errors.Wrapf(err, `failed to ship %s`, entry.Path)
+	}
+	return nil
+}
+
+// backoff doubles the retry delay with every attempt
+func (s *shipper) backoff(attempts int) time.Duration {
+	d := s.cfg.MinBackoff
+	for i := 1; i < attempts && d < s.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > s.cfg.MaxBackoff {
+		d = s.cfg.MaxBackoff
+	}
+	return d
+}
+
+// BeforeDelete vetoes the deletion of files that have not been shipped.
+// With compression, an uncompressed file is only shipped once it has
+// been compressed, so its deletion is vetoed until then.
+func (s *shipper) BeforeDelete(path string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.loadNolock(); err != nil {
+		return err
+	}
+	if entry, ok := s.pending[path]; ok && !entry.GaveUp {
+		return RetryAfter(time.Until(entry.NextAttempt), errors.Errorf(`%s has not been shipped yet`, path))
+	}
+	if s.compress && !strings.HasSuffix(path, common.CompressSuffix) {
+		return errors.Errorf(`%s has not been compressed and shipped yet`, path)
+	}
+	return nil
+}
+
+func (s *shipper) list() ([]Shipment, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.loadNolock(); err != nil {
+		return nil, err
+	}
+	entries := make([]Shipment, 0, len(s.pending))
+	for _, entry := range s.pending {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, nil
+}
+
+// stop interrupts the upload loop. Unfinished uploads stay in the journal.
+func (s *shipper) stop() {
+	if s == nil {
+		return
+	}
+	s.stopOnce.Do(func() {
+		s.startOnce.Do(func() { close(s.done) })
+		if s.cancel != nil {
+			s.cancel()
+		}
+		<-s.done
+	})
+}
+
+// PendingShipments lists the files waiting to be shipped.
+func (rl *RotateLogs) PendingShipments() ([]Shipment, error) {
+	if rl.shipper == nil {
+		return nil, nil
+	}
+	return rl.shipper.list()
+}
+
+// chainDeleteHooks returns a DeleteHook that allows a deletion only if
+// every non-nil hook allows it
+func chainDeleteHooks(hooks ...DeleteHook) DeleteHook {
+	var chain []DeleteHook
+	for _, h := range hooks {
+		if h != nil {
+			chain = append(chain, h)
+		}
+	}
+	switch len(chain) {
+	case 0:
+		return nil
+	case 1:
+		return chain[0]
+	}
+	return DeleteHookFunc(func(path string) error {
+		for _, h := range chain {
+			if err := h.BeforeDelete(path); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
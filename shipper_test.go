@@ -0,0 +1,215 @@
+package rotatelogs_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingStore is an ObjectStore that rejects every object
+type failingStore struct{}
+
+func (failingStore) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	return errors.New("store unavailable")
+}
+
+// waitEvent returns the next event of type t received on ch
+func waitEvent(t *testing.T, ch <-chan rotatelogs.Event, typ rotatelogs.EventType) rotatelogs.Event {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				assert.Fail(t, "the event channel was closed", "waiting for event type %v", typ)
+				return nil
+			}
+			if e.Type() == typ {
+				return e
+			}
+		case <-timeout:
+			assert.Fail(t, "timed out", "waiting for event type %v", typ)
+			return nil
+		}
+	}
+}
+
+func TestShipper(t *testing.T) {
+	t.Run("Journal is replayed after a restart", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		storeDir := tempDir(t)
+		defer os.RemoveAll(storeDir)
+
+		clock := clockwork.NewFakeClockAt(testTime)
+		options := func(store rotatelogs.ObjectStore) []rotatelogs.Option {
+			return []rotatelogs.Option{
+				rotatelogs.WithClock(clock),
+				rotatelogs.WithMaxAgeDuration(24 * time.Hour),
+				rotatelogs.WithShipper(rotatelogs.ShipperConfig{
+					Store:      store,
+					MinBackoff: 10 * time.Millisecond,
+					MaxBackoff: 10 * time.Millisecond,
+				}),
+			}
+		}
+
+		rl := newLogger(t, dir, options(failingStore{})...)
+		ch, _ := rl.Subscribe(64)
+		rl.Write([]byte("Hello, World\n"))
+		rotated := rl.CurrentFileName()
+		if !assert.NoError(t, rl.Rotate()) || waitEvent(t, ch, rotatelogs.ShippingFailedEventType) == nil {
+			rl.Close()
+			return
+		}
+
+		clock.Advance(72 * time.Hour)
+		if !assert.NoError(t, rl.RunMaintenance()) {
+			rl.Close()
+			return
+		}
+		assert.FileExists(t, rotated, "an unshipped file should not be deleted")
+		if vetoed := rl.VetoedFiles(); assert.Len(t, vetoed, 1) {
+			assert.Equal(t, rotated, vetoed[0].Path)
+		}
+		assert.NoError(t, rl.Close())
+
+		journal, err := ioutil.ReadFile(filepath.Join(dir, ".app.shipper.json"))
+		if !assert.NoError(t, err, "the journal should have been written") {
+			return
+		}
+		assert.Contains(t, string(journal), filepath.Base(rotated))
+
+		rl = newLogger(t, dir, options(rotatelogs.NewLocalObjectStore(storeDir))...)
+		defer rl.Close()
+		ch, _ = rl.Subscribe(64)
+		pending, err := rl.PendingShipments()
+		if assert.NoError(t, err) && assert.Len(t, pending, 1) {
+			assert.Equal(t, rotated, pending[0].Path)
+			assert.True(t, pending[0].Attempts > 0, "the attempts should have been journaled")
+		}
+
+		rl.Init()
+		if ev, ok := waitEvent(t, ch, rotatelogs.FileShippedEventType).(*rotatelogs.FileShippedEvent); ok {
+			assert.Equal(t, rotated, ev.File())
+		}
+		assert.FileExists(t, filepath.Join(storeDir, filepath.Base(rotated)))
+		// past the postponement of a maintenance run started by Init
+		// before the upload completed
+		clock.Advance(time.Minute)
+		if !assert.NoError(t, rl.RunMaintenance()) {
+			return
+		}
+		assertNoFile(t, rotated, "a shipped file should be deleted")
+		pending, err = rl.PendingShipments()
+		if assert.NoError(t, err) {
+			assert.Len(t, pending, 0)
+		}
+	})
+
+	t.Run("Uncompressed files are not deleted before shipping", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		storeDir := tempDir(t)
+		defer os.RemoveAll(storeDir)
+
+		old := writeLogFile(t, dir, testTime.AddDate(0, 0, -3), "old", testTime)
+		rl := newLogger(t, dir,
+			rotatelogs.WithClock(clockwork.NewFakeClockAt(testTime)),
+			rotatelogs.WithMaxAgeDuration(24*time.Hour),
+			rotatelogs.WithCompressFile(true),
+			rotatelogs.WithShipper(rotatelogs.ShipperConfig{Store: rotatelogs.NewLocalObjectStore(storeDir)}),
+		)
+		defer rl.Close()
+		ch, _ := rl.Subscribe(64)
+
+		if !assert.NoError(t, rl.RunMaintenance()) {
+			return
+		}
+		if waitEvent(t, ch, rotatelogs.FileShippedEventType) == nil {
+			return
+		}
+		assert.FileExists(t, filepath.Join(storeDir, filepath.Base(old)+".gz"), "the expired file should have been compressed and shipped")
+
+		if !assert.NoError(t, rl.RunMaintenance()) {
+			return
+		}
+		assertNoFile(t, old+".gz", "the shipped file should be deleted")
+	})
+
+	t.Run("Missing files are not retried", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+
+		gone := filepath.Join(dir, "app-2021-11-11.log")
+		journal := `[{"path":"` + gone + `","key":"app-2021-11-11.log","next_attempt":"2021-11-11T00:00:00Z"}]`
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, ".app.shipper.json"), []byte(journal), 0644)) {
+			return
+		}
+		rl := newLogger(t, dir,
+			rotatelogs.WithClock(clockwork.NewFakeClockAt(testTime)),
+			rotatelogs.WithShipper(rotatelogs.ShipperConfig{Store: failingStore{}}),
+		)
+		defer rl.Close()
+		ch, _ := rl.Subscribe(64)
+
+		rl.Write([]byte("Hello, World\n"))
+		if !assert.NoError(t, os.Remove(rl.CurrentFileName())) {
+			return
+		}
+		// rotates away from the missing file
+		rl.Write([]byte("Hello, World\n"))
+
+		rl.Init()
+		if ev, ok := waitEvent(t, ch, rotatelogs.ShippingFailedEventType).(*rotatelogs.ShippingFailedEvent); ok {
+			assert.Equal(t, gone, ev.File())
+			assert.True(t, ev.GaveUp(), "a missing file should be given up on")
+		}
+		pending, err := rl.PendingShipments()
+		if assert.NoError(t, err) {
+			assert.Len(t, pending, 0, "neither the missing nor the vanished current file should be pending")
+		}
+	})
+
+	t.Run("Giving up releases the veto", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+
+		clock := clockwork.NewFakeClockAt(testTime)
+		rl := newLogger(t, dir,
+			rotatelogs.WithClock(clock),
+			rotatelogs.WithMaxAgeDuration(24*time.Hour),
+			rotatelogs.WithShipper(rotatelogs.ShipperConfig{Store: failingStore{}, MaxAttempts: 1}),
+		)
+		defer rl.Close()
+		ch, _ := rl.Subscribe(64)
+
+		rl.Write([]byte("Hello, World\n"))
+		rotated := rl.CurrentFileName()
+		if !assert.NoError(t, rl.Rotate()) {
+			return
+		}
+		ev, ok := waitEvent(t, ch, rotatelogs.ShippingFailedEventType).(*rotatelogs.ShippingFailedEvent)
+		if !ok || !assert.True(t, ev.GaveUp()) {
+			return
+		}
+
+		clock.Advance(72 * time.Hour)
+		if !assert.NoError(t, rl.RunMaintenance()) {
+			return
+		}
+		assertNoFile(t, rotated, "files given up on should be subject to retention")
+		pending, err := rl.PendingShipments()
+		if assert.NoError(t, err) {
+			assert.Len(t, pending, 0, "deleted files should leave the journal")
+		}
+	})
+}
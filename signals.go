@@ -0,0 +1,137 @@
+package rotatelogs
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// SignalAction is what HandleSignals does when a signal arrives.
+type SignalAction int
+
+const (
+	// SignalRotate calls Rotate
+	SignalRotate SignalAction = iota + 1
+	// SignalReopen calls Reopen
+	SignalReopen
+	// SignalMaintenance calls RunMaintenance
+	SignalMaintenance
+)
+
+func (a SignalAction) String() string {
+	switch a {
+	case SignalRotate:
+		return "rotate"
+	case SignalReopen:
+		return "reopen"
+	case SignalMaintenance:
+		return "maintenance"
+	default:
+		return fmt.Sprintf("SignalAction(%d)", int(a))
+	}
+}
+
+// SignalOptions configures HandleSignals.
+type SignalOptions struct {
+	// Actions maps each signal to handle to its action. When nil,
+	// DefaultSignalActions is used.
+	Actions map[os.Signal]SignalAction
+	// OnError is called when an action fails. By default the error is
+	// printed to stderr.
+	OnError func(sig os.Signal, err error)
+}
+
+// DefaultSignalActions returns the signals handled by default: on Unix
+// systems SIGHUP rotates, SIGUSR1 reopens and SIGUSR2 runs maintenance.
+// Elsewhere it is empty.
+func DefaultSignalActions() map[os.Signal]SignalAction {
+	return defaultSignalActions()
+}
+
+// HandleSignals runs the action mapped to each signal of opts.Actions on
+// rl as the signal arrives, replacing the usual
+//
+//	signal.Notify(ch, syscall.SIGHUP)
+//	go func() { for range ch { rl.Rotate() } }()
+//
+// The signals are released by the returned stop function, or by Close,
+// whichever comes first. HandleSignals fails once rl has been closed.
+// opts.Actions is copied, changing the map afterwards has no effect.
+func HandleSignals(rl *RotateLogs, opts SignalOptions) (stop func(), err error) {
+	actions := make(map[os.Signal]SignalAction, len(opts.Actions))
+	for sig, action := range opts.Actions {
+		actions[sig] = action
+	}
+	if opts.Actions == nil {
+		actions = DefaultSignalActions()
+	}
+	sigs := make([]os.Signal, 0, len(actions))
+	for sig, action := range actions {
+		switch action {
+		case SignalRotate, SignalReopen, SignalMaintenance:
+		default:
+			return nil, errors.Errorf("invalid action %v for signal %v", action, sig)
+		}
+		sigs = append(sigs, sig)
+	}
+	onError := opts.OnError
+	if onError == nil {
+		onError = func(sig os.Signal, err error) {
+			rl.diag.printf("%s\n", err.Error())
+		}
+	}
+	if len(sigs) == 0 {
+		// nothing to handle, but a closed rl is still an error
+		return rl.onClose(func() {})
+	}
+
+	ch := make(chan os.Signal, len(sigs))
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+			<-exited
+		})
+	}
+	remove, err := rl.onClose(release)
+	if err != nil {
+		return nil, err
+	}
+	signal.Notify(ch, sigs...)
+	go func() {
+		defer close(exited)
+		for {
+			select {
+			case <-done:
+				return
+			case sig := <-ch:
+				var err error
+				switch actions[sig] {
+				case SignalRotate:
+					err = rl.Rotate()
+				case SignalReopen:
+					err = rl.Reopen()
+				case SignalMaintenance:
+					err = rl.RunMaintenance()
+				}
+				if err != nil {
+					onError(sig, errors.Wrapf(err, "failed to %v on %v", actions[sig], sig))
+				}
+			}
+		}
+	}()
+
+	// stopping before Close unregisters the hook, so that handling
+	// signals repeatedly does not accumulate hooks
+	stop = func() {
+		remove()
+		release()
+	}
+	return stop, nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly && !solaris && !aix
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly,!solaris,!aix
+
+package rotatelogs
+
+import "os"
+
+func defaultSignalActions() map[os.Signal]SignalAction {
+	return map[os.Signal]SignalAction{}
+}
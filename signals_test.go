@@ -0,0 +1,142 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly || solaris || aix
+// +build linux darwin freebsd netbsd openbsd dragonfly solaris aix
+
+package rotatelogs_test
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+	"github.com/stretchr/testify/assert"
+)
+
+// sendSignal sends sig to the test process. A channel of the test keeps
+// the signal from terminating the process once HandleSignals released it;
+// it receives every signal sent.
+func sendSignal(t *testing.T, sig syscall.Signal, guard <-chan os.Signal) {
+	if !assert.NoError(t, syscall.Kill(os.Getpid(), sig), "sending %v should succeed", sig) {
+		t.FailNow()
+	}
+	select {
+	case <-guard:
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "timed out", "waiting for %v", sig)
+	}
+}
+
+// assertNoEvent asserts that no event of type typ arrives on ch for a while.
+func assertNoEvent(t *testing.T, ch <-chan rotatelogs.Event, typ rotatelogs.EventType, msg string) {
+	timeout := time.After(200 * time.Millisecond)
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			if e.Type() == typ {
+				assert.Fail(t, msg, "got %v", typ)
+				return
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+func TestHandleSignals(t *testing.T) {
+	guard := make(chan os.Signal, 4)
+	signal.Notify(guard, syscall.SIGHUP, syscall.SIGUSR1)
+	defer signal.Stop(guard)
+
+	t.Run("Actions", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		rl := newLogger(t, dir)
+		defer rl.Close()
+		rl.Write([]byte("first\n"))
+
+		actions := map[os.Signal]rotatelogs.SignalAction{
+			syscall.SIGHUP:  rotatelogs.SignalRotate,
+			syscall.SIGUSR1: rotatelogs.SignalReopen,
+		}
+		stop, err := rotatelogs.HandleSignals(rl, rotatelogs.SignalOptions{Actions: actions})
+		if !assert.NoError(t, err, "HandleSignals should succeed") {
+			return
+		}
+		defer stop()
+		// the map is copied by HandleSignals
+		actions[syscall.SIGHUP] = rotatelogs.SignalMaintenance
+		events, cancel := rl.Subscribe(16)
+		defer cancel()
+
+		sendSignal(t, syscall.SIGHUP, guard)
+		if e, ok := waitEvent(t, events, rotatelogs.FileRotatedEventType).(*rotatelogs.FileRotatedEvent); ok {
+			assert.Equal(t, rotatelogs.RotationReasonManual, e.Reason(), "SIGHUP should rotate")
+		}
+		sendSignal(t, syscall.SIGUSR1, guard)
+		if e, ok := waitEvent(t, events, rotatelogs.FileReopenedEventType).(*rotatelogs.FileReopenedEvent); ok {
+			assert.Equal(t, rotatelogs.ReopenReasonManual, e.Reason(), "SIGUSR1 should reopen")
+		}
+	})
+
+	t.Run("Stop", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		rl := newLogger(t, dir)
+		defer rl.Close()
+		rl.Write([]byte("first\n"))
+
+		stop, err := rotatelogs.HandleSignals(rl, rotatelogs.SignalOptions{
+			Actions: map[os.Signal]rotatelogs.SignalAction{syscall.SIGHUP: rotatelogs.SignalRotate},
+		})
+		if !assert.NoError(t, err, "HandleSignals should succeed") {
+			return
+		}
+		events, cancel := rl.Subscribe(16)
+		defer cancel()
+		stop()
+		stop()
+
+		sendSignal(t, syscall.SIGHUP, guard)
+		assertNoEvent(t, events, rotatelogs.FileRotatedEventType, "the signal should be released by stop")
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+		rl := newLogger(t, dir)
+		rl.Write([]byte("first\n"))
+
+		stop, err := rotatelogs.HandleSignals(rl, rotatelogs.SignalOptions{
+			Actions: map[os.Signal]rotatelogs.SignalAction{syscall.SIGHUP: rotatelogs.SignalRotate},
+		})
+		if !assert.NoError(t, err, "HandleSignals should succeed") {
+			return
+		}
+		defer stop()
+		if !assert.NoError(t, rl.Close(), "Close should succeed") {
+			return
+		}
+
+		// the events are closed with rl, a rotation would show as a
+		// new file
+		sendSignal(t, syscall.SIGHUP, guard)
+		time.Sleep(200 * time.Millisecond)
+		matches, err := filepath.Glob(filepath.Join(dir, "app-*.log"))
+		if assert.NoError(t, err) {
+			assert.Len(t, matches, 1, "the signal should be released by Close")
+		}
+
+		_, err = rotatelogs.HandleSignals(rl, rotatelogs.SignalOptions{
+			Actions: map[os.Signal]rotatelogs.SignalAction{syscall.SIGHUP: rotatelogs.SignalRotate},
+		})
+		assert.Error(t, err, "HandleSignals should fail after Close")
+		_, err = rotatelogs.HandleSignals(rl, rotatelogs.SignalOptions{Actions: map[os.Signal]rotatelogs.SignalAction{}})
+		assert.Error(t, err, "HandleSignals should fail after Close even without signals")
+	})
+}
@@ -0,0 +1,17 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly || solaris || aix
+// +build linux darwin freebsd netbsd openbsd dragonfly solaris aix
+
+package rotatelogs
+
+import (
+	"os"
+	"syscall"
+)
+
+func defaultSignalActions() map[os.Signal]SignalAction {
+	return map[os.Signal]SignalAction{
+		syscall.SIGHUP:  SignalRotate,
+		syscall.SIGUSR1: SignalReopen,
+		syscall.SIGUSR2: SignalMaintenance,
+	}
+}
@@ -0,0 +1,107 @@
+// Package slogrotate provides a log/slog Handler that writes to a
+// RotateLogs object.
+package slogrotate
+
+import (
+	"context"
+	"log/slog"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+)
+
+// Format selects how records are rendered
+type Format int
+
+const (
+	// TextFormat renders records like slog.TextHandler
+	TextFormat Format = iota
+	// JSONFormat renders records like slog.JSONHandler
+	JSONFormat
+)
+
+// Options configures a Handler. The zero value writes text records at
+// level Info and above, and syncs the file on Error.
+type Options struct {
+	Format Format
+
+	// Level is the minimum level written (default slog.LevelInfo).
+	Level slog.Leveler
+	// MaxLevel, if set, is the maximum level written. Together with
+	// Level it lets each level range go to its own file.
+	MaxLevel slog.Leveler
+	// SyncLevel is the minimum level of the records after which the
+	// file is synced to stable storage (default slog.LevelError).
+	SyncLevel slog.Leveler
+
+	AddSource   bool
+	ReplaceAttr func(groups []string, a slog.Attr) slog.Attr
+}
+
+// Handler is a slog.Handler writing to a RotateLogs object. Every record
+// is rendered by the standard text or JSON handler, which issues a single
+// Write per record; since RotateLogs rotates between writes and never
+// within one, a record is never split across two files.
+type Handler struct {
+	rl        *rotatelogs.RotateLogs
+	inner     slog.Handler
+	maxLevel  slog.Leveler
+	syncLevel slog.Leveler
+}
+
+// NewHandler creates a Handler writing to rl. opts may be nil.
+func NewHandler(rl *rotatelogs.RotateLogs, opts *Options) *Handler {
+	if opts == nil {
+		opts = &Options{}
+	}
+	hopts := &slog.HandlerOptions{
+		AddSource:   opts.AddSource,
+		Level:       opts.Level,
+		ReplaceAttr: opts.ReplaceAttr,
+	}
+	var inner slog.Handler
+	switch opts.Format {
+	case JSONFormat:
+		inner = slog.NewJSONHandler(rl, hopts)
+	default:
+		inner = slog.NewTextHandler(rl, hopts)
+	}
+	syncLevel := opts.SyncLevel
+	if syncLevel == nil {
+		syncLevel = slog.LevelError
+	}
+	return &Handler{
+		rl:        rl,
+		inner:     inner,
+		maxLevel:  opts.MaxLevel,
+		syncLevel: syncLevel,
+	}
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	if h.maxLevel != nil && level > h.maxLevel.Level() {
+		return false
+	}
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if err := h.inner.Handle(ctx, r); err != nil {
+		return err
+	}
+	if r.Level >= h.syncLevel.Level() {
+		return h.rl.Sync()
+	}
+	return nil
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	cp := *h
+	cp.inner = h.inner.WithAttrs(attrs)
+	return &cp
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	cp := *h
+	cp.inner = h.inner.WithGroup(name)
+	return &cp
+}
@@ -0,0 +1,102 @@
+package slogrotate_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+	"github.com/chriszhangmq/file-rotatelogs/slogrotate"
+	"github.com/stretchr/testify/assert"
+)
+
+func newLogger(t *testing.T, name string) (*rotatelogs.RotateLogs, func()) {
+	dir, err := ioutil.TempDir("", "file-rotatelogs-slog")
+	if !assert.NoError(t, err, "creating temporary directory should succeed") {
+		t.FailNow()
+	}
+	rl, err := rotatelogs.New(
+		rotatelogs.WithFilePath(dir+"/"),
+		rotatelogs.WithFileName(name),
+		rotatelogs.WithMaxAge(7),
+	)
+	if !assert.NoError(t, err, `rotatelogs.New should succeed`) {
+		os.RemoveAll(dir)
+		t.FailNow()
+	}
+	return rl, func() {
+		rl.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func readCurrent(t *testing.T, rl *rotatelogs.RotateLogs) string {
+	content, err := ioutil.ReadFile(rl.CurrentFileName())
+	if !assert.NoError(t, err, "reading the current file should succeed") {
+		t.FailNow()
+	}
+	return string(content)
+}
+
+func TestHandlerLevels(t *testing.T) {
+	rl, cleanup := newLogger(t, "app")
+	defer cleanup()
+
+	logger := slog.New(slogrotate.NewHandler(rl, &slogrotate.Options{
+		Level:    slog.LevelDebug,
+		MaxLevel: slog.LevelWarn,
+	}))
+	logger.Debug("debug message")
+	logger.Warn("warn message", "k", "v")
+	logger.Error("error message")
+
+	content := readCurrent(t, rl)
+	assert.Contains(t, content, "msg=\"debug message\"")
+	assert.Contains(t, content, "msg=\"warn message\" k=v")
+	assert.NotContains(t, content, "error message")
+}
+
+func TestHandlerJSON(t *testing.T) {
+	rl, cleanup := newLogger(t, "app")
+	defer cleanup()
+
+	logger := slog.New(slogrotate.NewHandler(rl, &slogrotate.Options{Format: slogrotate.JSONFormat}))
+	logger.With("service", "api").WithGroup("req").Info("served", "status", 200)
+	logger.Debug("not written")
+
+	lines := strings.Split(strings.TrimSpace(readCurrent(t, rl)), "\n")
+	if !assert.Len(t, lines, 1, "only the info record should be written") {
+		return
+	}
+	var record map[string]interface{}
+	if !assert.NoError(t, json.Unmarshal([]byte(lines[0]), &record), "record should be valid JSON") {
+		return
+	}
+	assert.Equal(t, "served", record["msg"])
+	assert.Equal(t, "api", record["service"])
+	assert.Equal(t, map[string]interface{}{"status": float64(200)}, record["req"])
+}
+
+func TestHandlerRecordsSurviveRotation(t *testing.T) {
+	rl, cleanup := newLogger(t, "app")
+	defer cleanup()
+
+	logger := slog.New(slogrotate.NewHandler(rl, nil))
+	logger.Info("first")
+	first := rl.CurrentFileName()
+	if !assert.NoError(t, rl.Rotate(), "rl.Rotate should succeed") {
+		return
+	}
+	logger.Error("second")
+
+	content, err := ioutil.ReadFile(first)
+	if !assert.NoError(t, err, "reading the previous file should succeed") {
+		return
+	}
+	assert.Equal(t, 1, strings.Count(string(content), "\n"), "the previous file should hold exactly one record")
+	assert.Contains(t, string(content), "msg=first")
+	assert.Contains(t, readCurrent(t, rl), "msg=second")
+}
@@ -0,0 +1,205 @@
+package rotatelogs
+
+import (
+	"encoding/json"
+	"expvar"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Stats is a snapshot of what a RotateLogs object has been doing since
+// it was created.
+//
+// Files that grew when compressed add nothing to CompressedBytesSaved.
+// Deletions counts the files deleted or quarantined by the retention
+// policy, not the uncompressed copies removed after compression nor the
+// files purged from the trash.
+type Stats struct {
+	BytesWritten    uint64 `json:"bytes_written"`
+	Writes          uint64 `json:"writes"`
+	WriteErrors     uint64 `json:"write_errors"`
+	CurrentFile     string `json:"current_file"`
+	CurrentFileSize int64  `json:"current_file_size"`
+
+	Rotations        map[RotationReason]uint64 `json:"rotations"`
+	RotationFailures uint64                    `json:"rotation_failures"`
+	Reopens          uint64                    `json:"reopens"`
+	ReopenFailures   uint64                    `json:"reopen_failures"`
+
+	Compressions         uint64        `json:"compressions"`
+	CompressionFailures  uint64        `json:"compression_failures"`
+	CompressedBytesSaved int64         `json:"compressed_bytes_saved"`
+	CompressionDuration  time.Duration `json:"compression_duration"`
+
+	Deletions       uint64 `json:"deletions"`
+	VetoedDeletions uint64 `json:"vetoed_deletions"`
+	DroppedEvents   uint64 `json:"dropped_events"`
+
+	MaintenanceRuns         uint64        `json:"maintenance_runs"`
+	LastMaintenanceStart    time.Time     `json:"last_maintenance_start"`
+	LastMaintenanceDuration time.Duration `json:"last_maintenance_duration"`
+	MaintenanceDuration     time.Duration `json:"maintenance_duration"`
+
+	LastError     string    `json:"last_error,omitempty"`
+	LastErrorTime time.Time `json:"last_error_time,omitempty"`
+}
+
+// statsCollector accumulates the counters reported by Stats. Most of them
+// are derived from the events the object emits.
+type statsCollector struct {
+	mutex sync.Mutex
+	stats Stats
+
+	// outcome of the latest operations, reported by Health
+	lastWriteErr       error
+	lastRotationErr    error
+	lastMaintenanceErr error
+	lastMaintenanceOK  time.Time
+}
+
+func (c *statsCollector) observe(e Event) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	s := &c.stats
+	switch ev := e.(type) {
+	case *FileRotatedEvent:
+		if s.Rotations == nil {
+			s.Rotations = make(map[RotationReason]uint64)
+		}
+		s.Rotations[ev.reason]++
+		c.lastRotationErr = nil
+	case *RotationFailedEvent:
+		s.RotationFailures++
+		c.lastRotationErr = ev.err
+		c.recordErrorNolock(ev.err, ev.time)
+	case *FileCompressedEvent:
+		s.Compressions++
+		if saved := ev.originalSize - ev.compressedSize; saved > 0 {
+			s.CompressedBytesSaved += saved
+		}
+		s.CompressionDuration += ev.duration
+	case *FileReopenedEvent:
+		s.Reopens++
+	case *CompressionFailedEvent:
+		s.CompressionFailures++
+		c.recordErrorNolock(ev.err, ev.time)
+	case *FileDeletedEvent:
+		if ev.retention {
+			s.Deletions++
+		}
+	case *ShippingFailedEvent:
+		c.recordErrorNolock(ev.err, ev.time)
+	case *MaintenanceCompletedEvent:
+		s.MaintenanceRuns++
+		s.LastMaintenanceStart = ev.start
+		s.LastMaintenanceDuration = ev.duration
+		s.MaintenanceDuration += ev.duration
+		c.lastMaintenanceErr = ev.err
+		if ev.err != nil {
+			c.recordErrorNolock(ev.err, ev.start.Add(ev.duration))
+		} else {
+			c.lastMaintenanceOK = ev.start.Add(ev.duration)
+		}
+	}
+}
+
+func (c *statsCollector) write(n int, err error, now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.stats.BytesWritten += uint64(n)
+	c.stats.Writes++
+	c.lastWriteErr = err
+	if err != nil {
+		c.stats.WriteErrors++
+		c.recordErrorNolock(err, now)
+	}
+}
+
+// reopenFailed counts a failure to reopen the current file. Unlike the
+// other failures it is not reported by an event.
+func (c *statsCollector) reopenFailed(err error, now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.stats.ReopenFailures++
+	c.recordErrorNolock(err, now)
+}
+
+// must be locked during this operation
+func (c *statsCollector) recordErrorNolock(err error, t time.Time) {
+	if err == nil {
+		return
+	}
+	c.stats.LastError = err.Error()
+	c.stats.LastErrorTime = t
+}
+
+func (c *statsCollector) snapshot() Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	s := c.stats
+	s.Rotations = make(map[RotationReason]uint64, len(c.stats.Rotations))
+	for reason, n := range c.stats.Rotations {
+		s.Rotations[reason] = n
+	}
+	return s
+}
+
+// Stats returns a snapshot of the runtime statistics of the object.
+func (rl *RotateLogs) Stats() Stats {
+	s := rl.stats.snapshot()
+	s.VetoedDeletions = rl.vetoes.count()
+	s.DroppedEvents = rl.events.droppedCount()
+
+	rl.mutex.RLock()
+	s.CurrentFile = rl.curFn
+	rl.mutex.RUnlock()
+	if s.CurrentFile != "" {
+		if fi, err := os.Stat(s.CurrentFile); err == nil {
+			s.CurrentFileSize = fi.Size()
+		}
+	}
+	return s
+}
+
+// expvarStats is the expvar variable published by PublishExpvar. It
+// reports the statistics of the logger it was last published for.
+type expvarStats struct {
+	rl atomic.Value // *RotateLogs
+}
+
+func (v *expvarStats) String() string {
+	b, _ := json.Marshal(v.rl.Load().(*RotateLogs).Stats())
+	return string(b)
+}
+
+// serialises the check and the publication of PublishExpvar
+var expvarMutex sync.Mutex
+
+// PublishExpvar publishes the statistics of rl as the expvar variable
+// name. Publishing a name again, e.g. for a logger created anew, makes
+// the variable report rl instead; a name already used by a variable that
+// was not published by PublishExpvar is an error.
+func PublishExpvar(name string, rl *RotateLogs) error {
+	expvarMutex.Lock()
+	defer expvarMutex.Unlock()
+
+	switch v := expvar.Get(name).(type) {
+	case nil:
+		stats := &expvarStats{}
+		stats.rl.Store(rl)
+		expvar.Publish(name, stats)
+	case *expvarStats:
+		v.rl.Store(rl)
+	default:
+		return errors.Errorf("expvar %q is already in use", name)
+	}
+	return nil
+}
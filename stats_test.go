@@ -0,0 +1,157 @@
+package rotatelogs_test
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"expvar"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	t.Run("Deletions count only retention", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+
+		old := testTime.AddDate(0, 0, -3)
+		expired := writeLogFile(t, dir, old, "expired", old)
+		// a copy left behind by an interrupted compression
+		leftover := writeLogFile(t, dir, testTime, "leftover", testTime)
+		if !assert.NoError(t, ioutil.WriteFile(leftover+".gz", nil, 0644)) {
+			return
+		}
+
+		clock := clockwork.NewFakeClockAt(testTime)
+		rl := newLogger(t, dir,
+			rotatelogs.WithClock(clock),
+			rotatelogs.WithMaxAgeDuration(24*time.Hour),
+			rotatelogs.WithTrashGracePeriod(time.Hour),
+		)
+		defer rl.Close()
+
+		if !assert.NoError(t, rl.RunMaintenance()) {
+			return
+		}
+		assertNoFile(t, expired)
+		assertNoFile(t, leftover)
+		assert.EqualValues(t, 1, rl.Stats().Deletions, "the leftover copy should not be counted")
+
+		clock.Advance(2 * time.Hour)
+		if !assert.NoError(t, rl.RunMaintenance()) {
+			return
+		}
+		files, _ := rl.Quarantined()
+		assert.Len(t, files, 0, "the trash should have been purged")
+		assert.EqualValues(t, 1, rl.Stats().Deletions, "purging the trash should not count the file again")
+	})
+
+	t.Run("Compression savings are never negative", func(t *testing.T) {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+
+		random := make([]byte, 256)
+		if _, err := rand.Read(random); !assert.NoError(t, err) {
+			return
+		}
+		text := strings.Repeat("Hello, World\n", 100)
+		writeLogFile(t, dir, testTime.AddDate(0, 0, -2), string(random), testTime)
+		writeLogFile(t, dir, testTime.AddDate(0, 0, -1), text, testTime)
+
+		rl := newLogger(t, dir,
+			rotatelogs.WithClock(clockwork.NewFakeClockAt(testTime)),
+			rotatelogs.WithCompressFile(true),
+		)
+		defer rl.Close()
+		ch, _ := rl.Subscribe(16)
+
+		if !assert.NoError(t, rl.RunMaintenance()) {
+			return
+		}
+		var saved int64
+		for i := 0; i < 2; i++ {
+			ev, ok := waitEvent(t, ch, rotatelogs.FileCompressedEventType).(*rotatelogs.FileCompressedEvent)
+			if !ok {
+				return
+			}
+			if d := ev.OriginalSize() - ev.CompressedSize(); d > 0 {
+				saved += d
+			}
+		}
+		stats := rl.Stats()
+		assert.EqualValues(t, 2, stats.Compressions)
+		assert.True(t, saved > 0, "the text file should have shrunk")
+		assert.Equal(t, saved, stats.CompressedBytesSaved, "the random file should add nothing")
+	})
+}
+
+func TestPublishExpvar(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	// the expvar registry is global, the name must not clash with other
+	// runs of the test
+	name := "rotatelogs-test-" + filepath.Base(dir)
+
+	// the fields of Stats the test looks at, as published
+	type published struct {
+		BytesWritten uint64            `json:"bytes_written"`
+		Writes       uint64            `json:"writes"`
+		CurrentFile  string            `json:"current_file"`
+		Rotations    map[string]uint64 `json:"rotations"`
+	}
+	read := func() published {
+		var s published
+		v := expvar.Get(name)
+		if assert.NotNil(t, v, "the variable should be published") {
+			assert.NoError(t, json.Unmarshal([]byte(v.String()), &s), "the variable should be JSON")
+		}
+		return s
+	}
+
+	first := newLogger(t, dir, rotatelogs.WithClock(clockwork.NewFakeClockAt(testTime)))
+	defer first.Close()
+	first.Write([]byte("first\n"))
+	if !assert.NoError(t, rotatelogs.PublishExpvar(name, first), "PublishExpvar should succeed") {
+		return
+	}
+	s := read()
+	assert.EqualValues(t, len("first\n"), s.BytesWritten)
+	assert.EqualValues(t, 1, s.Writes)
+	assert.Equal(t, first.CurrentFileName(), s.CurrentFile)
+	assert.Equal(t, map[string]uint64{"startup": 1}, s.Rotations, "rotation reasons should be published by name")
+	first.Write([]byte("again\n"))
+	assert.EqualValues(t, 2, read().Writes, "the variable should report the current statistics")
+
+	t.Run("Publishing a name again", func(t *testing.T) {
+		second := newLogger(t, dir,
+			rotatelogs.WithClock(clockwork.NewFakeClockAt(testTime)),
+			rotatelogs.WithFileName("second"),
+		)
+		defer second.Close()
+		second.Write([]byte("second\n"))
+
+		if !assert.NotPanics(t, func() {
+			assert.NoError(t, rotatelogs.PublishExpvar(name, second), "publishing a name again should succeed")
+		}) {
+			return
+		}
+		s := read()
+		assert.EqualValues(t, 1, s.Writes, "the variable should report the logger published last")
+		assert.Equal(t, second.CurrentFileName(), s.CurrentFile)
+	})
+
+	t.Run("A name used by another variable", func(t *testing.T) {
+		other := name + "-int"
+		expvar.NewInt(other)
+		assert.Error(t, rotatelogs.PublishExpvar(other, first), "a name used by another variable should be rejected")
+		_, ok := expvar.Get(other).(*expvar.Int)
+		assert.True(t, ok, "the other variable should be left alone")
+	})
+}
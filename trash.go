@@ -0,0 +1,269 @@
+package rotatelogs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chriszhangmq/file-rotatelogs/internal/common"
+	"github.com/chriszhangmq/file-rotatelogs/internal/fileutil"
+	"github.com/pkg/errors"
+)
+
+// QuarantinedFile describes a log file that retention moved into the
+// trash directory instead of removing it.
+type QuarantinedFile struct {
+	Name          string    // base name the file had in the log directory
+	Path          string    // location of the file inside the trash directory
+	QuarantinedAt time.Time // when the file was moved into the trash
+	PurgeAt       time.Time // when the file will be removed for good
+}
+
+// trashIndex records when each file entered the trash, so quarantining
+// leaves the modification time of the file untouched. It is persisted as
+// a JSON sidecar file inside the trash directory and keyed by the base
+// name of the file in the trash.
+type trashIndex struct {
+	mutex sync.Mutex
+	path  string
+}
+
+type trashIndexFile struct {
+	Files map[string]time.Time `json:"files"`
+}
+
+// must be locked during this operation
+func (t *trashIndex) loadNolock() (map[string]time.Time, error) {
+	files := make(map[string]time.Time)
+	buf, err := ioutil.ReadFile(t.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return files, nil
+		}
+		return nil, errors.Wrapf(err, `failed to read trash index %s`, t.path)
+	}
+	var tf trashIndexFile
+	if err := json.Unmarshal(buf, &tf); err != nil {
+		return nil, errors.Wrapf(err, `failed to parse trash index %s`, t.path)
+	}
+	for name, at := range tf.Files {
+		files[name] = at
+	}
+	return files, nil
+}
+
+// must be locked during this operation
+func (t *trashIndex) saveNolock(files map[string]time.Time) error {
+	buf, err := json.MarshalIndent(trashIndexFile{Files: files}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(t.path, buf, 0644)
+}
+
+func (t *trashIndex) load() (map[string]time.Time, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.loadNolock()
+}
+
+// update applies fn to the index and saves the result
+func (t *trashIndex) update(fn func(map[string]time.Time)) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	files, err := t.loadNolock()
+	if err != nil {
+		return err
+	}
+	fn(files)
+	return t.saveNolock(files)
+}
+
+// trashDir returns the directory quarantined files are moved into. It is
+// shared by every logger writing to the same directory; ownership is
+// decided by trashMatcher.
+func (rl *RotateLogs) trashDir() string {
+	return filepath.Join(rl.matcher.Dir(), common.TrashDir)
+}
+
+func (rl *RotateLogs) trashMatcher() *fileutil.FileMatcher {
+	return fileutil.NewFileMatcher(rl.trashDir()+string(filepath.Separator), filepath.Base(rl.filePath+rl.fileName), common.TimeFormat)
+}
+
+// trashSuffix is appended to the name of a quarantined file when the
+// trash already holds a file of the same name, e.g. "app-2021-11-14.log~1"
+var trashSuffix = regexp.MustCompile(`~[0-9]+$`)
+
+// trashOrigin returns the name a file in the trash had in the log directory
+func trashOrigin(name string) string {
+	return trashSuffix.ReplaceAllString(filepath.Base(name), "")
+}
+
+// removeFile deletes a file on behalf of the retention policy. When a
+// trash grace period is configured the file is quarantined instead.
+func (rl *RotateLogs) removeFile(path string) error {
+	if rl.trashGracePeriod <= 0 {
+		return os.Remove(path)
+	}
+
+	dir := rl.trashDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrapf(err, `failed to create directory %s`, dir)
+	}
+	dst := filepath.Join(dir, filepath.Base(path))
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(dst); os.IsNotExist(err) {
+			break
+		}
+		dst = filepath.Join(dir, fmt.Sprintf("%s~%d", filepath.Base(path), i))
+	}
+	if err := os.Rename(path, dst); err != nil {
+		return errors.Wrapf(err, `failed to quarantine %s`, path)
+	}
+	now := rl.clock.Now()
+	err := rl.trash.update(func(files map[string]time.Time) {
+		files[filepath.Base(dst)] = now
+	})
+	if err != nil {
+		return errors.Wrapf(err, `failed to record quarantined file %s`, dst)
+	}
+	return nil
+}
+
+// Quarantined lists the files currently held in the trash directory.
+func (rl *RotateLogs) Quarantined() ([]QuarantinedFile, error) {
+	d, err := os.Open(rl.trashDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	names, err := d.Readdirnames(-1)
+	d.Close()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	index, err := rl.trash.load()
+	if err != nil {
+		return nil, err
+	}
+
+	matcher := rl.trashMatcher()
+	files := make([]QuarantinedFile, 0, len(names))
+	for _, name := range names {
+		if !matcher.Match(trashOrigin(name)) {
+			continue
+		}
+		path := filepath.Join(rl.trashDir(), name)
+		fi, err := os.Lstat(path)
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		// files quarantined before the index existed were stamped with
+		// the quarantine time instead
+		at, ok := index[name]
+		if !ok {
+			at = fi.ModTime()
+		}
+		files = append(files, QuarantinedFile{
+			Name:          trashOrigin(name),
+			Path:          path,
+			QuarantinedAt: at,
+			PurgeAt:       at.Add(rl.trashGracePeriod),
+		})
+	}
+	return files, nil
+}
+
+// Restore moves a quarantined file back into the log directory. name is
+// the base name of the Path reported by Quarantined. The restored file is
+// pinned, so that retention does not quarantine it again on the next
+// maintenance run; call Unpin to make it subject to retention again.
+func (rl *RotateLogs) Restore(name string) error {
+	name = filepath.Base(name)
+	src := filepath.Join(rl.trashDir(), name)
+	if !rl.trashMatcher().Match(trashOrigin(name)) {
+		return errors.Errorf(`%s is not managed by this logger`, name)
+	}
+	dst := filepath.Join(rl.matcher.Dir(), trashOrigin(name))
+	if _, err := os.Lstat(dst); err == nil {
+		return errors.Errorf(`failed to restore %s: %s already exists`, name, dst)
+	}
+	if err := rl.pins.set(pinKey(dst), true); err != nil {
+		return errors.Wrapf(err, `failed to pin %s`, dst)
+	}
+	if err := os.Rename(src, dst); err != nil {
+		rl.pins.set(pinKey(dst), false)
+		return errors.Wrapf(err, `failed to restore %s`, name)
+	}
+	return rl.trash.update(func(files map[string]time.Time) {
+		delete(files, name)
+	})
+}
+
+// ExpiredTrash returns the quarantined files whose grace period is over,
+// which the next maintenance run removes for good. Pinned files are
+// kept in the trash and not included.
+func (rl *RotateLogs) ExpiredTrash() ([]QuarantinedFile, error) {
+	files, err := rl.Quarantined()
+	if err != nil {
+		return nil, err
+	}
+	now := rl.clock.Now()
+	expired := make([]QuarantinedFile, 0, len(files))
+	for _, f := range files {
+		if now.Before(f.PurgeAt) || rl.pins.has(f.Name) {
+			continue
+		}
+		expired = append(expired, f)
+	}
+	return expired, nil
+}
+
+// 清除超过宽限期的隔离文件
+// The first failure to remove a file is returned after the other files
+// have been purged.
+func (rl *RotateLogs) purgeTrash() (int, error) {
+	files, err := rl.ExpiredTrash()
+	if err != nil {
+		return 0, err
+	}
+	purged := 0
+	var firstErr error
+	removed := make(map[string]struct{})
+	for _, f := range files {
+		fi, err := os.Stat(f.Path)
+		if err == nil {
+			err = os.Remove(f.Path)
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = errors.Wrapf(err, `failed to purge %s`, f.Path)
+			}
+			continue
+		}
+		purged++
+		removed[filepath.Base(f.Path)] = struct{}{}
+		rl.emit(&FileDeletedEvent{file: f.Path, size: fi.Size(), time: rl.clock.Now()})
+	}
+	if len(removed) > 0 {
+		err := rl.trash.update(func(files map[string]time.Time) {
+			for name := range removed {
+				delete(files, name)
+			}
+		})
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return purged, firstErr
+}
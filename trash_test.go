@@ -0,0 +1,109 @@
+package rotatelogs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrash(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	clock := clockwork.NewFakeClockAt(testTime)
+	rl := newLogger(t, dir,
+		rotatelogs.WithClock(clock),
+		rotatelogs.WithMaxAgeDuration(24*time.Hour),
+		rotatelogs.WithTrashGracePeriod(time.Hour),
+	)
+	defer rl.Close()
+
+	old := testTime.AddDate(0, 0, -3)
+	mtime := old.Add(time.Hour).Truncate(time.Second)
+	path := writeLogFile(t, dir, old, "first", mtime)
+	name := filepath.Base(path)
+	trashed := filepath.Join(dir, ".trash", name)
+
+	t.Run("Quarantine keeps the modification time", func(t *testing.T) {
+		if !assert.NoError(t, rl.RunMaintenance(), "maintenance should succeed") {
+			return
+		}
+		assertNoFile(t, path, "the expired file should have left the log directory")
+		fi, err := os.Stat(trashed)
+		if !assert.NoError(t, err, "the expired file should be in the trash") {
+			return
+		}
+		assert.True(t, fi.ModTime().Equal(mtime), "the modification time should be kept, got %s", fi.ModTime())
+
+		files, err := rl.Quarantined()
+		if !assert.NoError(t, err) || !assert.Len(t, files, 1) {
+			return
+		}
+		assert.Equal(t, name, files[0].Name)
+		assert.Equal(t, trashed, files[0].Path)
+		assert.True(t, files[0].QuarantinedAt.Equal(testTime), "the quarantine time should come from the clock")
+		assert.True(t, files[0].PurgeAt.Equal(testTime.Add(time.Hour)), "the file should be purged after the grace period")
+	})
+
+	t.Run("Same-named files get unique trash names", func(t *testing.T) {
+		writeLogFile(t, dir, old, "second", mtime)
+		if !assert.NoError(t, rl.RunMaintenance(), "maintenance should succeed") {
+			return
+		}
+		files, err := rl.Quarantined()
+		if !assert.NoError(t, err) || !assert.Len(t, files, 2) {
+			return
+		}
+		assert.Equal(t, name, files[0].Name)
+		assert.Equal(t, name, files[1].Name)
+		assert.Equal(t, trashed+"~1", files[1].Path)
+
+		content, err := ioutil.ReadFile(trashed)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "first", string(content), "the first quarantined file should not be overwritten")
+		}
+	})
+
+	t.Run("Restored files are not quarantined again", func(t *testing.T) {
+		if !assert.NoError(t, rl.Restore(name+"~1"), "restore should succeed") {
+			return
+		}
+		content, err := ioutil.ReadFile(path)
+		if !assert.NoError(t, err, "the file should be back in the log directory") {
+			return
+		}
+		assert.Equal(t, "second", string(content))
+		pinned, err := rl.Pinned()
+		if assert.NoError(t, err) {
+			assert.Equal(t, []string{name}, pinned, "the restored file should be pinned")
+		}
+
+		if !assert.NoError(t, rl.RunMaintenance(), "maintenance should succeed") {
+			return
+		}
+		assert.FileExists(t, path, "the restored file should survive the next maintenance run")
+		assert.Error(t, rl.Restore(name), "restoring over an existing file should fail")
+	})
+
+	t.Run("Files are purged after the grace period", func(t *testing.T) {
+		// a pin covers every file of that name, including the trash
+		if !assert.NoError(t, rl.Unpin(name)) || !assert.NoError(t, os.Remove(path)) {
+			return
+		}
+		clock.Advance(2 * time.Hour)
+		if !assert.NoError(t, rl.RunMaintenance(), "maintenance should succeed") {
+			return
+		}
+		files, err := rl.Quarantined()
+		if assert.NoError(t, err) {
+			assert.Len(t, files, 0, "the trash should be empty")
+		}
+		assertNoFile(t, trashed)
+	})
+}
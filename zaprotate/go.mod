@@ -0,0 +1,23 @@
+module github.com/chriszhangmq/file-rotatelogs/zaprotate
+
+go 1.19
+
+require (
+	github.com/chriszhangmq/file-rotatelogs v0.0.0-20261018134719-a84fb65ff66a
+	github.com/stretchr/testify v1.8.1
+	go.uber.org/zap v1.27.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/lestrrat-go/strftime v0.0.0-20180821113735-8b31f9c59b0f // indirect
+	github.com/pkg/errors v0.8.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/robfig/cron v1.2.0 // indirect
+	go.uber.org/multierr v1.10.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+// builds inside the repository use the module next door; the
+// requirement above is what consumers of this module resolve
+replace github.com/chriszhangmq/file-rotatelogs => ../
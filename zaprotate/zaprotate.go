@@ -0,0 +1,38 @@
+// Package zaprotate integrates RotateLogs with go.uber.org/zap.
+package zaprotate
+
+import (
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// WriteSyncer adapts rl to zapcore.WriteSyncer. Sync flushes the active
+// file to stable storage, so zap's Logger.Sync behaves as expected.
+func WriteSyncer(rl *rotatelogs.RotateLogs) zapcore.WriteSyncer {
+	return rl
+}
+
+// Route sends the entries enabled by Enabler to Logs.
+type Route struct {
+	Enabler zapcore.LevelEnabler
+	Logs    *rotatelogs.RotateLogs
+}
+
+// LevelRange enables the levels between min and max, both inclusive.
+func LevelRange(min, max zapcore.Level) zapcore.LevelEnabler {
+	return zap.LevelEnablerFunc(func(l zapcore.Level) bool {
+		return l >= min && l <= max
+	})
+}
+
+// NewCore builds a zapcore.Core that encodes entries with enc and writes
+// them to every route whose Enabler accepts their level, e.g. errors to
+// one file and everything to another.
+func NewCore(enc zapcore.Encoder, routes ...Route) zapcore.Core {
+	cores := make([]zapcore.Core, 0, len(routes))
+	for _, r := range routes {
+		cores = append(cores, zapcore.NewCore(enc.Clone(), WriteSyncer(r.Logs), r.Enabler))
+	}
+	return zapcore.NewTee(cores...)
+}
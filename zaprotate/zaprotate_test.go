@@ -0,0 +1,62 @@
+package zaprotate_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	rotatelogs "github.com/chriszhangmq/file-rotatelogs"
+	"github.com/chriszhangmq/file-rotatelogs/zaprotate"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestNewCore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-rotatelogs-zap")
+	if !assert.NoError(t, err, "creating temporary directory should succeed") {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	newLogs := func(name string) *rotatelogs.RotateLogs {
+		rl, err := rotatelogs.New(
+			rotatelogs.WithFilePath(dir+"/"),
+			rotatelogs.WithFileName(name),
+			rotatelogs.WithMaxAge(7),
+		)
+		if !assert.NoError(t, err, `rotatelogs.New should succeed`) {
+			t.FailNow()
+		}
+		return rl
+	}
+	all := newLogs("app")
+	defer all.Close()
+	errs := newLogs("app-error")
+	defer errs.Close()
+
+	enc := zapcore.NewConsoleEncoder(zap.NewProductionEncoderConfig())
+	logger := zap.New(zaprotate.NewCore(enc,
+		zaprotate.Route{Enabler: zapcore.DebugLevel, Logs: all},
+		zaprotate.Route{Enabler: zaprotate.LevelRange(zapcore.ErrorLevel, zapcore.FatalLevel), Logs: errs},
+	))
+	logger.Info("info message")
+	logger.Error("error message")
+	if !assert.NoError(t, logger.Sync(), "logger.Sync should succeed") {
+		return
+	}
+
+	content, err := ioutil.ReadFile(all.CurrentFileName())
+	if !assert.NoError(t, err, "reading the main file should succeed") {
+		return
+	}
+	assert.Contains(t, string(content), "info message")
+	assert.Contains(t, string(content), "error message")
+
+	content, err = ioutil.ReadFile(errs.CurrentFileName())
+	if !assert.NoError(t, err, "reading the error file should succeed") {
+		return
+	}
+	assert.NotContains(t, string(content), "info message")
+	assert.Contains(t, string(content), "error message")
+}